@@ -14,7 +14,7 @@ func BenchmarkLoadConfigFile(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := LoadConfigFile(jsonPath)
+		_, err := LoadConfigFile(jsonPath, "")
 		if err != nil {
 			b.Fatalf("LoadConfigFile failed: %v", err)
 		}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values of onError
+const (
+	onErrorStop     = "stop"
+	onErrorContinue = "continue"
+)
+
+// StepConfig is an entry of `steps` or `dependsOn`. Config files write it as
+// the name of a command in the same file, as a reference with `ref` and an
+// optional `file`, or as an inline command.
+type StepConfig struct {
+	Ref           string `json:"ref,omitempty" yaml:"ref,omitempty" toml:"ref,omitempty"`
+	File          string `json:"file,omitempty" yaml:"file,omitempty" toml:"file,omitempty"`
+	CommandConfig `yaml:",inline"`
+}
+
+// stepFields decodes the mapping form of a step without recursing into the
+// custom unmarshalers
+type stepFields StepConfig
+
+// UnmarshalJSON accepts a command name or an object
+func (s *StepConfig) UnmarshalJSON(data []byte) error {
+	var ref string
+	if err := json.Unmarshal(data, &ref); err == nil {
+		*s = StepConfig{Ref: ref}
+		return nil
+	}
+	return json.Unmarshal(data, (*stepFields)(s))
+}
+
+// UnmarshalYAML accepts a command name or a mapping
+func (s *StepConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = StepConfig{Ref: node.Value}
+		return nil
+	}
+	return node.Decode((*stepFields)(s))
+}
+
+// UnmarshalTOML accepts a command name or a table
+func (s *StepConfig) UnmarshalTOML(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*s = StepConfig{Ref: v}
+		return nil
+	case map[string]interface{}:
+		// The table uses the same keys as the JSON form
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, (*stepFields)(s))
+	}
+	return fmt.Errorf("step must be a command name or a table, got %T", value)
+}
+
+// label returns how the step is referred to in messages
+func (s StepConfig) label() string {
+	switch {
+	case s.Ref != "" && s.File != "":
+		return s.File + "#" + s.Ref
+	case s.Ref != "":
+		return s.Ref
+	}
+	return s.Name
+}
+
+// isChain reports whether the command runs steps or dependencies
+func (c CommandConfig) isChain() bool {
+	return len(c.Steps) > 0 || len(c.DependsOn) > 0
+}
+
+// allSteps returns the dependencies, steps and parallel commands of the command
+func (c CommandConfig) allSteps() []StepConfig {
+	var steps []StepConfig
+	steps = append(steps, c.DependsOn...)
+	steps = append(steps, c.Steps...)
+	return append(steps, c.Parallel...)
+}
+
+// stepSummary describes the steps of a command, e.g. "Build → Migrate → Start",
+// or its parallel commands, e.g. "API | Web"
+func (c CommandConfig) stepSummary() string {
+	steps, separator := c.Steps, " → "
+	if len(c.Parallel) > 0 {
+		steps, separator = c.Parallel, " | "
+	}
+	labels := make([]string, len(steps))
+	for i, step := range steps {
+		labels[i] = step.label()
+	}
+	return strings.Join(labels, separator)
+}
+
+// checkSteps reports invalid step declarations and references to commands of
+// the same file that do not exist. References to other files are resolved
+// when the command is executed.
+func checkSteps(config *ConfigFile, cmd CommandConfig) error {
+	switch cmd.OnError {
+	case "", onErrorStop, onErrorContinue:
+	default:
+		return fmt.Errorf("invalid onError %q (want %q or %q)", cmd.OnError, onErrorStop, onErrorContinue)
+	}
+	if len(cmd.Steps) > 0 && (cmd.Command != "" || cmd.Script != "") {
+		return fmt.Errorf("a command with steps cannot also set command or script")
+	}
+	if len(cmd.Parallel) > 0 && (cmd.Command != "" || cmd.Script != "" || len(cmd.Steps) > 0) {
+		return fmt.Errorf("a parallel command cannot also set command, script or steps")
+	}
+	config = cmd.stepsConfig(config)
+
+	for _, step := range cmd.allSteps() {
+		if step.Ref == "" {
+			if step.File != "" {
+				return fmt.Errorf("step with file %q needs a ref", step.File)
+			}
+			if err := checkSteps(config, step.CommandConfig); err != nil {
+				return fmt.Errorf("step %q: %w", step.Name, err)
+			}
+			continue
+		}
+		if step.File != "" {
+			continue
+		}
+		target := findStepCommand(config, step.Ref)
+		if target == nil {
+			return fmt.Errorf("step %q: no command with that name", step.Ref)
+		}
+		if err := checkStepParams(*target); err != nil {
+			return fmt.Errorf("step %q: %w", step.Ref, err)
+		}
+	}
+	return nil
+}
+
+// stepsConfig returns the config file the step references of c are resolved
+// against: the file c was included from, or config, the file it was found in
+func (c CommandConfig) stepsConfig(config *ConfigFile) *ConfigFile {
+	if c.source != nil {
+		return c.source
+	}
+	return config
+}
+
+// checkStepParams reports a parameter of cmd that has no default, which it
+// needs to run as a step: steps run with the defaults of their parameters
+func checkStepParams(cmd CommandConfig) error {
+	for _, p := range cmd.Params {
+		if p.Default == "" && p.Kind() != paramBool {
+			return fmt.Errorf("parameter %q has no default to run the command as a step", p.Name)
+		}
+	}
+	return nil
+}
+
+// findStepCommand returns the command with the given name, or nil
+func findStepCommand(config *ConfigFile, name string) *CommandConfig {
+	for i := range config.Commands {
+		if config.Commands[i].Name == name {
+			return &config.Commands[i]
+		}
+	}
+	return nil
+}
+
+// planStep is one command of an execution plan
+type planStep struct {
+	config  *ConfigFile
+	command CommandConfig
+}
+
+// planner flattens steps and dependencies into the list of commands to run
+type planner struct {
+	steps   []planStep
+	stack   []string
+	planned map[string]bool
+	configs map[string]*ConfigFile
+}
+
+// buildPlan returns the commands that executing cmd runs, in order: its
+// dependencies (each at most once), then its steps, or the command itself
+// when it has no steps. Steps and dependencies are expanded recursively.
+func buildPlan(config *ConfigFile, cmd CommandConfig) ([]planStep, error) {
+	p := &planner{
+		planned: make(map[string]bool),
+		configs: map[string]*ConfigFile{config.Path: config},
+	}
+	if err := p.add(config, cmd); err != nil {
+		return nil, err
+	}
+	return p.steps, nil
+}
+
+// add appends the plan of cmd
+func (p *planner) add(config *ConfigFile, cmd CommandConfig) error {
+	config = cmd.stepsConfig(config)
+	key := config.Path + "#" + cmd.Name
+	for _, active := range p.stack {
+		if active == key {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(p.stackNames(), cmd.Name), " → "))
+		}
+	}
+	p.stack = append(p.stack, key)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+
+	for _, dep := range cmd.DependsOn {
+		depConfig, depCmd, err := p.resolve(config, dep)
+		if err != nil {
+			return fmt.Errorf("command %q: dependency %q: %w", cmd.Name, dep.label(), err)
+		}
+		depKey := depConfig.Path + "#" + depCmd.Name
+		if p.planned[depKey] {
+			continue
+		}
+		if err := p.add(depConfig, depCmd); err != nil {
+			return err
+		}
+		p.planned[depKey] = true
+	}
+
+	if len(cmd.Steps) == 0 {
+		if cmd.Command != "" || cmd.Script != "" || len(cmd.Parallel) > 0 {
+			p.steps = append(p.steps, planStep{config: config, command: cmd})
+		}
+		return nil
+	}
+
+	for _, step := range cmd.Steps {
+		stepConfig, stepCmd, err := p.resolve(config, step)
+		if err != nil {
+			return fmt.Errorf("command %q: step %q: %w", cmd.Name, step.label(), err)
+		}
+		if err := p.add(stepConfig, stepCmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stackNames returns the command names of the commands being expanded
+func (p *planner) stackNames() []string {
+	names := make([]string, len(p.stack))
+	for i, key := range p.stack {
+		names[i] = key[strings.LastIndex(key, "#")+1:]
+	}
+	return names
+}
+
+// resolve returns the config file and command a step refers to, with the
+// defaults of its parameters applied. Files are looked up relative to the
+// referencing config file.
+func (p *planner) resolve(config *ConfigFile, step StepConfig) (*ConfigFile, CommandConfig, error) {
+	if step.Ref == "" {
+		return config, step.CommandConfig, nil
+	}
+
+	target := config
+	if step.File != "" {
+		file := step.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(config.Path), file)
+		}
+		path, err := resolveConfigPath(filepath.Dir(config.Path), file)
+		if err != nil {
+			return nil, CommandConfig{}, err
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, CommandConfig{}, err
+		}
+		if target = p.configs[path]; target == nil {
+			if target, err = LoadConfigFile(path, config.rootDir); err != nil {
+				return nil, CommandConfig{}, err
+			}
+			p.configs[path] = target
+		}
+	}
+
+	cmd := findStepCommand(target, step.Ref)
+	if cmd == nil {
+		return nil, CommandConfig{}, fmt.Errorf("no command with that name in %s", displayPath(target.Path))
+	}
+	if len(cmd.Params) == 0 {
+		return target, *cmd, nil
+	}
+
+	// A referenced command runs with the defaults of its parameters
+	if err := checkStepParams(*cmd); err != nil {
+		return nil, CommandConfig{}, err
+	}
+	values, err := ResolveParams(*cmd, nil)
+	if err != nil {
+		return nil, CommandConfig{}, err
+	}
+	return target, ApplyParams(*cmd, values), nil
+}
+
+// StepResult is the outcome of one step of a chain
+type StepResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+	Skipped  bool
+	Stopped  bool
+}
+
+// ExecuteChain runs the dependencies and steps of cmd in order, printing a
+// header before each step and a summary at the end. Unless onError is
+// "continue", the first failing step stops the chain and the remaining steps
+// are skipped. The error of the first failing step is returned.
+func (e *CommandExecutor) ExecuteChain(config *ConfigFile, cmd CommandConfig) error {
+	steps, err := buildPlan(config, cmd)
+	if err != nil {
+		return err
+	}
+
+	results := make([]StepResult, len(steps))
+	var firstErr error
+	for i, step := range steps {
+		results[i].Name = step.command.Name
+		if firstErr != nil && cmd.OnError != onErrorContinue {
+			results[i].Skipped = true
+			continue
+		}
+
+		fmt.Printf("\n==> [%d/%d] %s\n", i+1, len(steps), step.command.Name)
+		start := time.Now()
+		var err error
+		if len(step.command.Parallel) > 0 {
+			err = e.ExecuteParallel(step.config, step.command)
+		} else {
+			err = e.ExecuteCommand(step.command, step.config.Show)
+		}
+		results[i].Duration = time.Since(start)
+		if err != nil {
+			results[i].Err = err
+			if firstErr == nil {
+				firstErr = fmt.Errorf("step %q failed: %w", step.command.Name, err)
+			}
+		}
+	}
+
+	printStepSummary(results)
+	return firstErr
+}
+
+// printStepSummary prints which steps passed, failed or were skipped
+func printStepSummary(results []StepResult) {
+	width := 0
+	for _, result := range results {
+		if len(result.Name) > width {
+			width = len(result.Name)
+		}
+	}
+
+	passed := 0
+	fmt.Println("\nSummary:")
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			fmt.Printf("  - %-*s  skipped\n", width, result.Name)
+		case result.Stopped:
+			fmt.Printf("  - %-*s  stopped (%s)\n", width, result.Name, result.Duration.Round(time.Millisecond))
+		case result.Err != nil:
+			code, fromChild := ExitCode(result.Err)
+			status := fmt.Sprintf("exit %d", code)
+			if !fromChild {
+				status = result.Err.Error()
+			}
+			fmt.Printf("  ✗ %-*s  %s (%s)\n", width, result.Name, status, result.Duration.Round(time.Millisecond))
+		default:
+			passed++
+			fmt.Printf("  ✓ %-*s  %s\n", width, result.Name, result.Duration.Round(time.Millisecond))
+		}
+	}
+	fmt.Printf("%d of %d steps passed\n", passed, len(results))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStepDecoding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	files := map[string]string{
+		"chain.yml": `name: Chain
+commands:
+  - name: Build
+    command: make
+  - name: Release
+    steps:
+      - Build
+      - ref: Deploy
+        file: ops.yml
+      - command: git tag v1
+`,
+		"chain.json": `{"name": "Chain", "commands": [
+  {"name": "Build", "command": "make"},
+  {"name": "Release", "steps": ["Build", {"ref": "Deploy", "file": "ops.yml"}, {"command": "git tag v1"}]}
+]}`,
+		"chain.toml": `name = "Chain"
+
+[[commands]]
+name = "Build"
+command = "make"
+
+[[commands]]
+name = "Release"
+steps = ["Build", { ref = "Deploy", file = "ops.yml" }, { command = "git tag v1" }]
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfigFile(writeTestFile(t, configDir, name, content), "")
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+			steps := config.Commands[1].Steps
+			if len(steps) != 3 {
+				t.Fatalf("Expected 3 steps, got %+v", steps)
+			}
+			if steps[0].Ref != "Build" || steps[1].Ref != "Deploy" || steps[1].File != "ops.yml" {
+				t.Errorf("Unexpected references: %+v", steps[:2])
+			}
+			if steps[2].Command != "git tag v1" || steps[2].Name != "Release step 3" {
+				t.Errorf("Unexpected inline step: %+v", steps[2])
+			}
+		})
+	}
+}
+
+func TestStepsCheckedAtLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	broken := map[string]string{
+		"unknown-ref.yml": "name: X\ncommands:\n  - name: A\n    steps: [Missing]\n",
+		"on-error.yml":    "name: X\ncommands:\n  - name: A\n    command: echo\n    onError: ignore\n",
+		"both.yml":        "name: X\ncommands:\n  - name: B\n    command: echo\n  - name: A\n    command: echo\n    steps: [B]\n",
+		"inline.yml":      "name: X\ncommands:\n  - name: A\n    steps:\n      - command: \"echo 'unterminated\"\n",
+	}
+	for name, content := range broken {
+		if _, err := LoadConfigFile(writeTestFile(t, configDir, name, content), ""); err == nil {
+			t.Errorf("Expected %s to fail to load", name)
+		}
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	writeTestFile(t, configDir, "ops/db.yml", `name: DB
+commands:
+  - name: Migrate
+    command: migrate up
+    dependsOn: [{ref: Build, file: ../app.yml}]
+`)
+	path := writeTestFile(t, configDir, "app.yml", `name: App
+commands:
+  - name: Build
+    command: make
+  - name: Start
+    command: ./server
+    dependsOn:
+      - Build
+      - {ref: Migrate, file: ops/db}
+  - name: Release
+    onError: continue
+    steps:
+      - Start
+      - name: Tag
+        command: git tag v1
+  - name: Loop
+    steps: [Again]
+  - name: Again
+    dependsOn: [Loop]
+    command: echo
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	steps, err := buildPlan(config, *findStepCommand(config, "Release"))
+	if err != nil {
+		t.Fatalf("buildPlan() error = %v", err)
+	}
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.command.Name)
+	}
+	// Build is a dependency of both Start and Migrate but runs once
+	if got := strings.Join(names, ","); got != "Build,Migrate,Start,Tag" {
+		t.Errorf("buildPlan() = %s, want Build,Migrate,Start,Tag", got)
+	}
+	if steps[1].config.Name != "DB" {
+		t.Errorf("Expected Migrate to keep its own config file, got %q", steps[1].config.Name)
+	}
+
+	_, err = buildPlan(config, *findStepCommand(config, "Loop"))
+	if err == nil || !strings.Contains(err.Error(), "Loop → Again → Loop") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
+
+func TestExecuteChain(t *testing.T) {
+	dir := t.TempDir()
+	config := &ConfigFile{Name: "Chain", Path: filepath.Join(dir, "chain.yml")}
+	config.Commands = []CommandConfig{
+		{Name: "First", Command: "touch first", WorkDir: dir},
+		{Name: "Fail", Command: "sh -c 'exit 4'"},
+		{Name: "Last", Command: "touch last", WorkDir: dir},
+	}
+	chain := CommandConfig{Name: "All", Steps: []StepConfig{{Ref: "First"}, {Ref: "Fail"}, {Ref: "Last"}}}
+
+	executor := NewCommandExecutor()
+	err := executor.ExecuteChain(config, chain)
+	if code, _ := ExitCode(err); code != 4 {
+		t.Errorf("Expected exit code 4 from the failing step, got %d (%v)", code, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "first")); err != nil {
+		t.Error("Expected the first step to run")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "last")); err == nil {
+		t.Error("Expected the chain to stop at the failing step")
+	}
+
+	chain.OnError = onErrorContinue
+	if err := executor.ExecuteChain(config, chain); err == nil {
+		t.Error("Expected the failing step to be reported with onError: continue")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "last")); err != nil {
+		t.Error("Expected the chain to continue after the failing step")
+	}
+}
+
+func TestStepsUseParamDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	path := writeTestFile(t, configDir, "greet.yml", `name: Greet
+commands:
+  - name: Greet
+    command: echo hi ${param.who}
+    params:
+      - name: who
+        default: world
+  - name: All
+    steps: [Greet]
+  - name: Both
+    parallel: [Greet]
+`)
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	for _, name := range []string{"All", "Both"} {
+		cmd := *findStepCommand(config, name)
+		p := &planner{configs: map[string]*ConfigFile{config.Path: config}}
+		_, step, err := p.resolve(config, cmd.allSteps()[0])
+		if err != nil {
+			t.Fatalf("resolve() error = %v", err)
+		}
+		if step.Command != "echo hi world" {
+			t.Errorf("%s runs Greet as %q, want the default of its parameter", name, step.Command)
+		}
+	}
+
+	// A parameter without a default cannot be given to a step
+	writeTestFile(t, configDir, "other.yml", `name: Other
+commands:
+  - name: Greet
+    command: echo hi ${param.who}
+    params:
+      - name: who
+`)
+	broken := writeTestFile(t, configDir, "broken.yml", `name: Broken
+commands:
+  - name: Greet
+    command: echo hi ${param.who}
+    params:
+      - name: who
+  - name: All
+    steps: [Greet]
+`)
+	if _, err := LoadConfigFile(broken, ""); err == nil || !strings.Contains(err.Error(), `parameter "who" has no default`) {
+		t.Errorf("Expected the step to be reported at load, got %v", err)
+	}
+	cmd := CommandConfig{Name: "Remote", Steps: []StepConfig{{Ref: "Greet", File: "other.yml"}}}
+	if _, err := buildPlan(config, cmd); err == nil || !strings.Contains(err.Error(), `parameter "who" has no default`) {
+		t.Errorf("Expected buildPlan() to refuse the step, got %v", err)
+	}
+}
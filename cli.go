@@ -0,0 +1,574 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// listedCommand is the JSON representation of a command in `seli list --json`
+type listedCommand struct {
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description,omitempty"`
+	Command     string    `json:"command"`
+	Args        []string  `json:"args,omitempty"`
+	Shell       ShellSpec `json:"shell,omitempty"`
+	Script      string    `json:"script,omitempty"`
+	WorkDir     string    `json:"workDir,omitempty"`
+}
+
+// listedConfig is the JSON representation of a config file in `seli list --json`
+type listedConfig struct {
+	Root        string          `json:"root,omitempty"`
+	File        string          `json:"file"`
+	Path        string          `json:"path"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Commands    []listedCommand `json:"commands"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// paramFlag collects repeated --param NAME=VALUE flags
+type paramFlag map[string]string
+
+func (p paramFlag) String() string {
+	pairs := make([]string, 0, len(p))
+	for name, value := range p {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p paramFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	p[name] = val
+	return nil
+}
+
+// parseFlags parses args with fs, allowing flags to appear between positional
+// arguments, and returns the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		// The flag package drops a "--" terminator; everything after it is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// runCommand implements `seli run <file> <command>`
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	params := paramFlag{}
+	fs.Var(params, "param", "set a command parameter as `NAME=VALUE` (repeatable)")
+	yes := fs.Bool("yes", false, "run commands that need confirmation without asking")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli run [--yes] [--param NAME=VALUE]... <file> <command>")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	roots, err := ConfigRoots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	path, err := resolveRootConfigPath(roots, positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	config, err := LoadConfigFile(path, rootDirOf(roots, path))
+	if err == nil {
+		err = checkProfile(config)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	command, err := findCommand(config, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	values, err := ResolveParams(*command, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := confirmCLI(config, *command, *yes); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	return commandExitCode(executeAndRecord(NewCommandExecutor(), config, ApplyParams(*command, values), values))
+}
+
+// showHistory implements `seli history [--json] [-n N]`
+func showHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the history as JSON")
+	limit := fs.Int("n", 20, "number of executions to show (0 for all)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli history [--json] [-n N]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Most recent first
+	recent := make([]HistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && (*limit <= 0 || len(recent) < *limit); i-- {
+		recent = append(recent, entries[i])
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(recent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	for i, entry := range recent {
+		profile := ""
+		if entry.Profile != "" {
+			profile = " [" + entry.Profile + "]"
+		}
+		fmt.Printf("%3d  %s  exit %-3d %8s  %s › %s%s\n      %s\n",
+			i+1,
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.ExitCode,
+			entry.Duration.Round(time.Millisecond),
+			displayPath(entry.ConfigPath),
+			entry.Command,
+			profile,
+			JoinCommandLine(entry.Argv))
+	}
+	return 0
+}
+
+// runLast implements `seli last [N]`, running the Nth most recent execution
+// again with the same parameters
+func runLast(args []string) int {
+	fs := flag.NewFlagSet("last", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "run commands that need confirmation without asking")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli last [--yes] [N]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	n := 1
+	if len(positional) == 1 {
+		if n, err = strconv.Atoi(positional[0]); err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Error: invalid history position %q\n", positional[0])
+			return 2
+		}
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if n > len(entries) {
+		fmt.Fprintf(os.Stderr, "Error: history has only %d executions\n", len(entries))
+		return 1
+	}
+
+	roots, err := ConfigRoots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	config, cmd, values, err := loadHistoryCommand(entries[len(entries)-n], roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := confirmCLI(config, cmd, *yes); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	return commandExitCode(executeAndRecord(NewCommandExecutor(), config, cmd, values))
+}
+
+// listCommands implements `seli list [--json]`
+func listCommands(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli list [--json]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	roots, err := ConfigRoots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var configs []listedConfig
+	for _, root := range roots {
+		rootConfigs, err := collectConfigs(root.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for i := range rootConfigs {
+			rootConfigs[i].Root = root.Name
+		}
+		configs = append(configs, rootConfigs...)
+	}
+
+	if *asJSON {
+		err = writeConfigsJSON(os.Stdout, configs)
+	} else {
+		err = writeConfigsText(os.Stdout, configs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, config := range configs {
+		if config.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// collectConfigs loads every config file below the config root configDir,
+// skipping hidden files and directories. Files that fail to load are reported
+// with an error.
+func collectConfigs(configDir string) ([]listedConfig, error) {
+	var configs []listedConfig
+
+	rootDir := ConfigRoot{Path: configDir}.Dir()
+	err := walkConfigFiles(configDir, func(path, rel string) error {
+		listed := listedConfig{
+			File:     rel,
+			Path:     path,
+			Commands: []listedCommand{},
+		}
+
+		config, err := LoadConfigFile(path, rootDir)
+		if err != nil {
+			listed.Error = err.Error()
+			configs = append(configs, listed)
+			return nil
+		}
+
+		listed.Name = config.Name
+		listed.Description = config.Description
+		for _, cmd := range config.Commands {
+			listed.Commands = append(listed.Commands, listedCommand{
+				Name:        cmd.Name,
+				Slug:        slugify(cmd.Name),
+				Description: cmd.Description,
+				Command:     cmd.Command,
+				Args:        cmd.Args,
+				Shell:       cmd.Shell,
+				Script:      cmd.Script,
+				WorkDir:     commandDir(cmd),
+			})
+		}
+		configs = append(configs, listed)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// walkConfigFiles calls fn for every config file below configDir in lexical
+// order, skipping hidden files and directories. rel is the slash-separated
+// path of the file relative to configDir. When configDir is a single config
+// file, fn is called for it alone.
+func walkConfigFiles(configDir string, fn func(path, rel string) error) error {
+	info, err := os.Stat(configDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil && !info.IsDir() {
+		return fn(configDir, filepath.Base(configDir))
+	}
+
+	err = filepath.WalkDir(configDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != configDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !IsConfigFile(d.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(configDir, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan config directory %s: %w", configDir, err)
+	}
+	return nil
+}
+
+// writeConfigsJSON writes the listing as indented JSON
+func writeConfigsJSON(w io.Writer, configs []listedConfig) error {
+	if configs == nil {
+		configs = []listedConfig{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(configs)
+}
+
+// writeConfigsText writes the listing as plain text, one command per line.
+// When the configs come from more than one root, each root gets a header.
+func writeConfigsText(w io.Writer, configs []listedConfig) error {
+	multipleRoots := false
+	for _, config := range configs {
+		if config.Root != configs[0].Root {
+			multipleRoots = true
+		}
+	}
+
+	for i, config := range configs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if multipleRoots && (i == 0 || config.Root != configs[i-1].Root) {
+			fmt.Fprintf(w, "[%s]\n", config.Root)
+		}
+		if config.Error != "" {
+			fmt.Fprintf(w, "%s\n  error: %s\n", config.File, config.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s (%s)\n", config.File, config.Name)
+
+		width := 0
+		for _, cmd := range config.Commands {
+			if len(cmd.Name) > width {
+				width = len(cmd.Name)
+			}
+		}
+		for _, cmd := range config.Commands {
+			description := cmd.Description
+			if description == "" {
+				description = strings.TrimSpace(strings.Join(append([]string{cmd.Command}, cmd.Args...), " "))
+			}
+			if _, err := fmt.Fprintf(w, "  %-*s  %s\n", width, cmd.Name, description); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveConfigPath resolves the <file> argument of `seli run`. The argument is
+// tried relative to the current directory first and then relative to
+// configDir; when it has no extension every supported extension is tried.
+func resolveConfigPath(configDir, arg string) (string, error) {
+	var candidates []string
+	for _, base := range []string{"", configDir} {
+		path := arg
+		if base != "" && !filepath.IsAbs(arg) {
+			path = filepath.Join(base, arg)
+		}
+		candidates = append(candidates, path)
+		if filepath.Ext(arg) == "" {
+			for _, ext := range []string{".yml", ".yaml", ".json", ".toml"} {
+				candidates = append(candidates, path+ext)
+			}
+		}
+	}
+
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() && IsConfigFile(path) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("config file %q not found in current directory or %s", arg, configDir)
+}
+
+// resolveRootConfigPath resolves the <file> argument of `seli run` against
+// every config root in order. Single-file roots match their file name with or
+// without extension.
+func resolveRootConfigPath(roots []ConfigRoot, arg string) (string, error) {
+	var names []string
+	for _, root := range roots {
+		names = append(names, root.Name)
+		if root.IsFile() {
+			base := filepath.Base(root.Path)
+			if arg == base || arg == strings.TrimSuffix(base, filepath.Ext(base)) {
+				return root.Path, nil
+			}
+			continue
+		}
+		if path, err := resolveConfigPath(root.Path, arg); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("config file %q not found in current directory or %s", arg, strings.Join(names, ", "))
+}
+
+// findCommand selects a command from config by exact name, case-insensitive
+// name, slug or an unambiguous fuzzy match, in that order
+func findCommand(config *ConfigFile, selector string) (*CommandConfig, error) {
+	for i := range config.Commands {
+		if config.Commands[i].Name == selector {
+			return &config.Commands[i], nil
+		}
+	}
+
+	for i := range config.Commands {
+		if strings.EqualFold(config.Commands[i].Name, selector) {
+			return &config.Commands[i], nil
+		}
+	}
+
+	slug := slugify(selector)
+	for i := range config.Commands {
+		if slug != "" && slugify(config.Commands[i].Name) == slug {
+			return &config.Commands[i], nil
+		}
+	}
+
+	names := make([]string, len(config.Commands))
+	for i, cmd := range config.Commands {
+		names[i] = strings.ToLower(cmd.Name)
+	}
+	matches := fuzzy.Find(strings.ToLower(selector), names)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no command matching %q in %s", selector, config.Name)
+	case 1:
+		return &config.Commands[matches[0].Index], nil
+	}
+
+	candidates := make([]string, len(matches))
+	for i, match := range matches {
+		candidates[i] = config.Commands[match.Index].Name
+	}
+	sort.Strings(candidates)
+	return nil, fmt.Errorf("command %q is ambiguous in %s: %s", selector, config.Name, strings.Join(candidates, ", "))
+}
+
+// slugify turns a command name into a lower-case, dash-separated identifier
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Deploy Staging", "deploy-staging"},
+		{"  Show Fruit A ", "show-fruit-a"},
+		{"git: status (short)", "git-status-short"},
+		{"already-slugged", "already-slugged"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.input); got != tt.expected {
+			t.Errorf("slugify(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	config := &ConfigFile{
+		Name: "Ops",
+		Commands: []CommandConfig{
+			{Name: "Deploy Staging", Command: "deploy staging"},
+			{Name: "Deploy Production", Command: "deploy production"},
+			{Name: "Tail Logs", Command: "logs -f"},
+		},
+	}
+
+	tests := []struct {
+		selector    string
+		expected    string
+		expectError bool
+	}{
+		{"Deploy Staging", "Deploy Staging", false},
+		{"deploy production", "Deploy Production", false},
+		{"deploy-staging", "Deploy Staging", false},
+		{"tail", "Tail Logs", false},
+		{"deploy", "", true},
+		{"nothing-like-this", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			cmd, err := findCommand(config, tt.selector)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("findCommand(%q) error = %v, expectError %v", tt.selector, err, tt.expectError)
+			}
+			if err == nil && cmd.Name != tt.expected {
+				t.Errorf("findCommand(%q) = %q, want %q", tt.selector, cmd.Name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveConfigPath(t *testing.T) {
+	configDir := t.TempDir()
+	opsDir := filepath.Join(configDir, "ops")
+	if err := os.MkdirAll(opsDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	deployFile := filepath.Join(opsDir, "deploy.yml")
+	if err := os.WriteFile(deployFile, []byte("name: Deploy\ncommands: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	for _, arg := range []string{"ops/deploy.yml", "ops/deploy", deployFile} {
+		path, err := resolveConfigPath(configDir, arg)
+		if err != nil {
+			t.Errorf("resolveConfigPath(%q) error = %v", arg, err)
+			continue
+		}
+		if path != deployFile {
+			t.Errorf("resolveConfigPath(%q) = %q, want %q", arg, path, deployFile)
+		}
+	}
+
+	if _, err := resolveConfigPath(configDir, "ops/missing.yml"); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestCollectConfigs(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configDir, "ops"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(configDir, ".hidden"), 0755); err != nil {
+		t.Fatalf("Failed to create hidden directory: %v", err)
+	}
+
+	files := map[string]string{
+		"ops/deploy.yml":    "name: Deploy\ncommands:\n  - name: Deploy Staging\n    command: echo staging\n",
+		"broken.json":       "{",
+		".hidden/skip.json": `{"name": "Hidden", "commands": []}`,
+		"notes.txt":         "not a config file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	configs, err := collectConfigs(configDir)
+	if err != nil {
+		t.Fatalf("collectConfigs() error = %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 configs, got %d: %+v", len(configs), configs)
+	}
+
+	if configs[0].File != "broken.json" || configs[0].Error == "" {
+		t.Errorf("Expected broken.json to be reported with an error, got %+v", configs[0])
+	}
+	if configs[1].File != "ops/deploy.yml" || len(configs[1].Commands) != 1 {
+		t.Fatalf("Expected ops/deploy.yml with one command, got %+v", configs[1])
+	}
+	if configs[1].Commands[0].Slug != "deploy-staging" {
+		t.Errorf("Expected slug 'deploy-staging', got %q", configs[1].Commands[0].Slug)
+	}
+
+	var buf bytes.Buffer
+	if err := writeConfigsJSON(&buf, configs); err != nil {
+		t.Fatalf("writeConfigsJSON() error = %v", err)
+	}
+	var decoded []listedConfig
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON output is not valid: %v", err)
+	}
+	if len(decoded) != 2 {
+		t.Errorf("Expected 2 configs in JSON output, got %d", len(decoded))
+	}
+
+	buf.Reset()
+	if err := writeConfigsText(&buf, configs); err != nil {
+		t.Fatalf("writeConfigsText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Deploy Staging") {
+		t.Errorf("Expected text output to contain the command name, got:\n%s", buf.String())
+	}
+}
+
+func TestParseFlagsInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "")
+
+	positional, err := parseFlags(fs, []string{"file.yml", "-v", "Deploy", "--", "-literal", "-v"})
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+	if !*verbose {
+		t.Error("Expected -v to be parsed after a positional argument")
+	}
+	expected := []string{"file.yml", "Deploy", "-literal", "-v"}
+	if strings.Join(positional, ",") != strings.Join(expected, ",") {
+		t.Errorf("parseFlags() positional = %v, want %v", positional, expected)
+	}
+}
@@ -1,85 +1,272 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
 	"strings"
-
-	"github.com/BurntSushi/toml"
-	"gopkg.in/yaml.v3"
 )
 
 // CommandConfig represents a single command configuration
 type CommandConfig struct {
-	Name        string            `json:"name" yaml:"name" toml:"name"`
-	Description string            `json:"description" yaml:"description" toml:"description"`
-	Command     string            `json:"command" yaml:"command" toml:"command"`
-	Args        []string          `json:"args,omitempty" yaml:"args,omitempty" toml:"args,omitempty"`
-	Env         map[string]string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
-	WorkDir     string            `json:"workDir,omitempty" yaml:"workDir,omitempty" toml:"workDir,omitempty"`
-	Show        *bool             `json:"show,omitempty" yaml:"show,omitempty" toml:"show,omitempty"`
+	Name         string            `json:"name" yaml:"name" toml:"name"`
+	Description  string            `json:"description" yaml:"description" toml:"description"`
+	Command      string            `json:"command" yaml:"command" toml:"command"`
+	Args         []string          `json:"args,omitempty" yaml:"args,omitempty" toml:"args,omitempty"`
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	WorkDir      string            `json:"workDir,omitempty" yaml:"workDir,omitempty" toml:"workDir,omitempty"`
+	Show         *bool             `json:"show,omitempty" yaml:"show,omitempty" toml:"show,omitempty"`
+	EnvFiles     []string          `json:"envFiles,omitempty" yaml:"envFiles,omitempty" toml:"envFiles,omitempty"`
+	ExportDotenv *bool             `json:"exportDotenv,omitempty" yaml:"exportDotenv,omitempty" toml:"exportDotenv,omitempty"`
+	Shell        ShellSpec         `json:"shell,omitempty" yaml:"shell,omitempty" toml:"shell,omitempty"`
+	Script       string            `json:"script,omitempty" yaml:"script,omitempty" toml:"script,omitempty"`
+	Params       []ParamConfig     `json:"params,omitempty" yaml:"params,omitempty" toml:"params,omitempty"`
+	Steps        []StepConfig      `json:"steps,omitempty" yaml:"steps,omitempty" toml:"steps,omitempty"`
+	DependsOn    []StepConfig      `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty" toml:"dependsOn,omitempty"`
+	Parallel     []StepConfig      `json:"parallel,omitempty" yaml:"parallel,omitempty" toml:"parallel,omitempty"`
+	OnError      string            `json:"onError,omitempty" yaml:"onError,omitempty" toml:"onError,omitempty"`
+	Confirm      ConfirmSpec       `json:"confirm,omitempty" yaml:"confirm,omitempty" toml:"confirm,omitempty"`
+	Secret       []string          `json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"`
+	Timeout      string            `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Extends      string            `json:"extends,omitempty" yaml:"extends,omitempty" toml:"extends,omitempty"`
+
+	// dotenv holds the .env variables exported to the child process
+	dotenv map[string]string
+	// projectRoot is the project directory a relative WorkDir is resolved against
+	projectRoot string
+	// source is the included file the command comes from, whose commands
+	// and directory its step references are resolved against
+	source *ConfigFile
+	// passwords holds the values of the password params applied to the
+	// command, which are masked like secrets
+	passwords []string
+	// undefinedVars are the ${VAR} references that could not be resolved
+	undefinedVars []undefinedVar
+	// defaultEnv is the env inherited from defaults, below the profile's env
+	defaultEnv map[string]string
+	// origins names where inherited settings come from, keyed by field name
+	// or "env.NAME"; settings of the command itself have no entry
+	origins map[string]string
+}
+
+// undefinedVar is a ${VAR} reference to a variable that is not defined
+type undefinedVar struct {
+	// Field is the path of the field within the command, e.g. "args.0"
+	Field string
+	Name  string
 }
 
 // ConfigFile represents a configuration file containing multiple commands
 type ConfigFile struct {
-	Name        string          `json:"name" yaml:"name" toml:"name"`
-	Description string          `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
-	Show        *bool           `json:"show,omitempty" yaml:"show,omitempty" toml:"show,omitempty"`
-	Commands    []CommandConfig `json:"commands" yaml:"commands" toml:"commands"`
+	// Schema is the "$schema" key that points editors at seli.schema.json
+	Schema       string                   `json:"$schema,omitempty" yaml:"$schema,omitempty" toml:"$schema,omitempty"`
+	Name         string                   `json:"name" yaml:"name" toml:"name"`
+	Description  string                   `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Show         *bool                    `json:"show,omitempty" yaml:"show,omitempty" toml:"show,omitempty"`
+	Stay         *bool                    `json:"stay,omitempty" yaml:"stay,omitempty" toml:"stay,omitempty"`
+	Dangerous    bool                     `json:"dangerous,omitempty" yaml:"dangerous,omitempty" toml:"dangerous,omitempty"`
+	EnvFiles     []string                 `json:"envFiles,omitempty" yaml:"envFiles,omitempty" toml:"envFiles,omitempty"`
+	ExportDotenv *bool                    `json:"exportDotenv,omitempty" yaml:"exportDotenv,omitempty" toml:"exportDotenv,omitempty"`
+	Shell        ShellSpec                `json:"shell,omitempty" yaml:"shell,omitempty" toml:"shell,omitempty"`
+	Defaults     *DefaultsConfig          `json:"defaults,omitempty" yaml:"defaults,omitempty" toml:"defaults,omitempty"`
+	Profiles     map[string]ProfileConfig `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
+	Include      []string                 `json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"`
+	Templates    map[string]CommandConfig `json:"templates,omitempty" yaml:"templates,omitempty" toml:"templates,omitempty"`
+	Commands     []CommandConfig          `json:"commands" yaml:"commands" toml:"commands"`
+
+	// Path is the absolute path the file was loaded from
+	Path string `json:"-" yaml:"-" toml:"-"`
+
+	// profile is the name of the profile the commands were expanded with
+	profile string
+	// rootDir is the directory of the config root the file was found in,
+	// where the search for _defaults files stops, or "" when unknown
+	rootDir string
+}
+
+// LoadConfigFile loads a configuration file from the given path. rootDir is
+// the directory of the config root the file was found in (see rootDirOf), or
+// "" when it is not known. Keys that seli does not know are errors; errors in
+// the file are *ConfigError values that carry the line and column.
+func LoadConfigFile(path, rootDir string) (*ConfigFile, error) {
+	return loadConfigFile(path, rootDir, true)
 }
 
-// LoadConfigFile loads a configuration file from the given path
-func LoadConfigFile(path string) (*ConfigFile, error) {
+// loadConfigFile loads a configuration file, ignoring unknown keys unless
+// strict is set
+func loadConfigFile(path, rootDir string, strict bool) (*ConfigFile, error) {
+	return loadConfig(path, rootDir, strict, nil)
+}
+
+// loadConfig loads a configuration file. chain holds the absolute paths of
+// the files that include it, outermost first, and is empty for a file that is
+// not included.
+func loadConfig(path, rootDir string, strict bool, chain []string) (*ConfigFile, error) {
 	ext := strings.ToLower(filepath.Ext(path))
+	config, data, err := readConfigFile(path, strict)
+	if err != nil {
+		return nil, err
+	}
+	config.rootDir = rootDir
 
-	data, err := os.ReadFile(path)
+	// Templates of included files are used as if they were written in this
+	// file, then commands are completed from their templates. Included
+	// commands are already complete and are added once this file's own are.
+	included, err := resolveIncludes(config, path, ext, data, strict, chain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		return nil, err
+	}
+	for i := range config.Commands {
+		if err := extendCommand(&config.Commands[i], config.Templates, nil); err != nil {
+			return nil, commandError(path, ext, data, config.Commands[i].Name, err)
+		}
 	}
 
-	var config ConfigFile
+	// Set default name from filename if not provided
+	if config.Name == "" {
+		config.Name = strings.TrimSuffix(filepath.Base(path), ext)
+	}
+
+	if config.Path, err = filepath.Abs(path); err != nil {
+		config.Path = path
+	}
+
+	// Commands inherit the settings they do not set from the file's defaults,
+	// its file-level keys and the _defaults files of its directories
+	layers, err := defaultsLayers(config, rootDir, path, ext, data, strict)
+	if err != nil {
+		return nil, err
+	}
+	// A relative workDir of an included file outside a project is anchored
+	// to its own directory rather than to where seli runs
+	projectRoot := projectRootOf(config.Path)
+	if projectRoot == "" && len(chain) > 0 {
+		projectRoot = filepath.Dir(config.Path)
+	}
+	for i := range config.Commands {
+		config.Commands[i].projectRoot = projectRoot
+		inheritStepSettings(&config.Commands[i])
+		applyDefaults(&config.Commands[i], layers)
+	}
 
+	// Process environment variables
+	if err := ProcessConfigWithEnv(config, path); err != nil {
+		if missing, ok := err.(*varError); ok {
+			pos, _ := configPositions(ext, data)
+			return nil, newConfigError(path, data, pos.find(missing.path), missing)
+		}
+		return nil, fmt.Errorf("failed to process environment variables: %w", err)
+	}
+	config.Commands = append(config.Commands, included...)
+
+	// Report malformed command lines, parameters and steps now rather than when they are executed
+	for _, cmd := range config.Commands {
+		if err := checkCommandLine(cmd); err != nil {
+			return nil, commandError(path, ext, data, cmd.Name, err)
+		}
+		if err := checkTimeout(cmd.Timeout); err != nil {
+			return nil, commandError(path, ext, data, cmd.Name, err)
+		}
+		if err := checkParams(cmd); err != nil {
+			return nil, commandError(path, ext, data, cmd.Name, err)
+		}
+		if err := checkSteps(config, cmd); err != nil {
+			return nil, commandError(path, ext, data, cmd.Name, err)
+		}
+	}
+
+	return config, nil
+}
+
+// readConfigFile reads and decodes a configuration file, ignoring unknown
+// keys unless strict is set. It also returns the contents of the file.
+func readConfigFile(path string, strict bool) (*ConfigFile, []byte, error) {
+	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
-	case ".json":
-		err = json.Unmarshal(data, &config)
-	case ".yaml", ".yml":
-		err = yaml.Unmarshal(data, &config)
-	case ".toml":
-		_, err = toml.Decode(string(data), &config)
+	case ".json", ".yaml", ".yml", ".toml":
 	default:
-		return nil, fmt.Errorf("unsupported file format: %s", ext)
+		return nil, nil, fmt.Errorf("unsupported file format: %s", ext)
 	}
 
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		return nil, nil, fmt.Errorf("failed to read file %s: %w", path, err)
 	}
 
-	// Set default name from filename if not provided
-	if config.Name == "" {
-		config.Name = strings.TrimSuffix(filepath.Base(path), ext)
+	var config ConfigFile
+	if err := decodeConfig(ext, data, &config, strict); err != nil {
+		return nil, nil, decodeError(path, ext, data, &config, err)
 	}
+	if strict {
+		// Steps are decoded by custom unmarshalers that do not see the strict setting
+		if err := firstUnknownField(path, ext, data, reflect.TypeOf(config)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &config, data, nil
+}
 
-	// Process environment variables
-	if err := ProcessConfigWithEnv(&config, path); err != nil {
-		return nil, fmt.Errorf("failed to process environment variables: %w", err)
+// inheritStepSettings names unnamed inline steps after their position and
+// passes the shell, project root and secret variables of cmd down to them
+func inheritStepSettings(cmd *CommandConfig) {
+	for _, steps := range [][]StepConfig{cmd.DependsOn, cmd.Steps, cmd.Parallel} {
+		for i := range steps {
+			step := &steps[i]
+			if step.Ref != "" {
+				continue
+			}
+			if step.Name == "" {
+				step.Name = fmt.Sprintf("%s step %d", cmd.Name, i+1)
+			}
+			if step.Shell == "" {
+				step.Shell = cmd.Shell
+			}
+			step.projectRoot = cmd.projectRoot
+			if len(cmd.Secret) > 0 {
+				step.Secret = append(append([]string(nil), step.Secret...), cmd.Secret...)
+			}
+			inheritStepSettings(&step.CommandConfig)
+		}
+	}
+}
+
+// checkCommandLine reports unterminated quotes in the command lines of cmd and
+// its inline steps
+func checkCommandLine(cmd CommandConfig) error {
+	if cmd.Script == "" && !cmd.Shell.Enabled() && len(cmd.Args) == 0 {
+		if _, err := SplitCommandLine(cmd.Command); err != nil {
+			return err
+		}
+	}
+	for _, step := range cmd.allSteps() {
+		if step.Ref == "" {
+			if err := checkCommandLine(step.CommandConfig); err != nil {
+				return fmt.Errorf("step %q: %w", step.Name, err)
+			}
+		}
 	}
+	return nil
+}
 
-	return &config, nil
+// ConfigDirPath returns the path of the ~/.seli/ directory without creating it
+func ConfigDirPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, ".seli"), nil
 }
 
 // ScanConfigDir scans ~/.seli/ directory for configuration files
 func ScanConfigDir() (string, []os.DirEntry, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := ConfigDirPath()
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get home directory: %w", err)
+		return "", nil, err
 	}
 
-	configDir := filepath.Join(homeDir, ".seli")
-
 	// Create config directory if it doesn't exist
 	if _, err := os.Stat(configDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(configDir, 0755); err != nil {
@@ -98,7 +285,7 @@ func ScanConfigDir() (string, []os.DirEntry, error) {
 // IsConfigFile checks if a file is a supported configuration file
 func IsConfigFile(name string) bool {
 	ext := strings.ToLower(filepath.Ext(name))
-	return ext == ".json" || ext == ".yaml" || ext == ".yml" || ext == ".toml"
+	return (ext == ".json" || ext == ".yaml" || ext == ".yml" || ext == ".toml") && !isDefaultsFile(name)
 }
 
 // LoadEnvFile loads .env file from the given directory and its parent directories
@@ -108,9 +295,9 @@ func LoadEnvFile(configDir string) (map[string]string, error) {
 	// First, check the config directory itself
 	envFile := filepath.Join(configDir, ".env")
 	if _, err := os.Stat(envFile); err == nil {
-		fileEnvVars, err := parseEnvFile(envFile)
+		fileEnvVars, err := parseEnvFile(envFile, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse .env file %s: %w", envFile, err)
+			return nil, envFileError(envFile, err)
 		}
 		for k, v := range fileEnvVars {
 			envVars[k] = v
@@ -132,9 +319,9 @@ func LoadEnvFile(configDir string) (map[string]string, error) {
 
 		envFile = filepath.Join(currentDir, ".env")
 		if _, err := os.Stat(envFile); err == nil {
-			fileEnvVars, err := parseEnvFile(envFile)
+			fileEnvVars, err := parseEnvFile(envFile, nil)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse .env file %s: %w", envFile, err)
+				return nil, envFileError(envFile, err)
 			}
 			// Parent directory variables are loaded only if not already set
 			for k, v := range fileEnvVars {
@@ -155,70 +342,61 @@ func LoadEnvFile(configDir string) (map[string]string, error) {
 	return envVars, nil
 }
 
-// parseEnvFile parses a .env file and returns environment variables
-func parseEnvFile(envFile string) (map[string]string, error) {
-	envVars := make(map[string]string)
-
-	file, err := os.Open(envFile)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+// loadEnvFiles loads the given .env files in order, later files overriding
+// earlier ones. Relative paths are resolved against baseDir.
+func loadEnvFiles(baseDir string, files []string, envVars map[string]string) (map[string]string, error) {
+	loaded := make(map[string]string)
+	for _, file := range files {
+		path := ExpandEnvVars(file, envVars)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
 		}
-
-		// Parse KEY=VALUE format
-		if strings.Contains(line, "=") {
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-
-				// Remove quotes if present
-				if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-				   (strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
-					value = value[1 : len(value)-1]
-				}
-
-				envVars[key] = value
-			}
+		// Files see the variables of the files before them
+		scope := make(map[string]string, len(envVars)+len(loaded))
+		for k, v := range envVars {
+			scope[k] = v
+		}
+		for k, v := range loaded {
+			scope[k] = v
+		}
+		fileEnvVars, err := parseEnvFile(path, scope)
+		if err != nil {
+			return nil, envFileError(path, err)
+		}
+		for k, v := range fileEnvVars {
+			loaded[k] = v
 		}
 	}
+	return loaded, nil
+}
 
-	return envVars, scanner.Err()
+// envFileError describes an error loading the .env file at path. Syntax
+// errors already name the file and line.
+func envFileError(path string, err error) error {
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return err
+	}
+	return fmt.Errorf("failed to parse .env file %s: %w", path, err)
 }
 
-// ExpandEnvVars expands environment variables in a string with escape support
-func ExpandEnvVars(input string, envVars map[string]string) string {
-	// Regular expression to match ${VAR_NAME} and \$ escape sequences
-	re := regexp.MustCompile(`\\\$|\$\{([^}]+)\}`)
-
-	return re.ReplaceAllStringFunc(input, func(match string) string {
-		switch match {
-		case "\\$":
-			return "$" // Unescape
-		case "\\${":
-			return "${" // Unescape
-		default:
-			// Match ${VAR_NAME}
-			if strings.HasPrefix(match, "${") && strings.HasSuffix(match, "}") {
-				varName := match[2 : len(match)-1]
-				if value, exists := envVars[varName]; exists {
-					return value
-				}
-				// Fallback to system environment
-				return os.Getenv(varName)
-			}
-			return match
+// withSystemEnv returns a copy of envVars completed with the system
+// environment variables that envVars does not define
+func withSystemEnv(envVars map[string]string) map[string]string {
+	merged := make(map[string]string, len(envVars))
+	for k, v := range envVars {
+		merged[k] = v
+	}
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 {
+			continue
 		}
-	})
+		if _, exists := merged[parts[0]]; !exists {
+			merged[parts[0]] = parts[1]
+		}
+	}
+	return merged
 }
 
 // ProcessConfigWithEnv processes configuration file with environment variable expansion
@@ -227,56 +405,169 @@ func ProcessConfigWithEnv(config *ConfigFile, configPath string) error {
 	configDir := filepath.Dir(configPath)
 
 	// Load .env files
-	envVars, err := LoadEnvFile(configDir)
+	dotenv, err := LoadEnvFile(configDir)
 	if err != nil {
 		return fmt.Errorf("failed to load .env files: %w", err)
 	}
 
-	// Add system environment variables (lower priority)
-	for _, env := range os.Environ() {
-		if !strings.Contains(env, "=") {
-			continue
-		}
-		parts := strings.SplitN(env, "=", 2)
-		if len(parts) == 2 {
-			if _, exists := envVars[parts[0]]; !exists {
-				envVars[parts[0]] = parts[1]
-			}
-		}
+	// File-level env files override the .env chain
+	fileEnvVars, err := loadEnvFiles(configDir, config.EnvFiles, withSystemEnv(dotenv))
+	if err != nil {
+		return err
+	}
+	for k, v := range fileEnvVars {
+		dotenv[k] = v
 	}
 
+	// The active profile's env files override the file-level ones
+	if err := applyProfile(config, configDir, dotenv); err != nil {
+		return err
+	}
 
 	// Process environment variable expansion for all commands
 	for i := range config.Commands {
-		// First, expand env values using global environment variables
-		expandedEnv := make(map[string]string)
-		for k, v := range config.Commands[i].Env {
-			expandedEnv[k] = ExpandEnvVars(v, envVars)
+		err := processCommandEnv(&config.Commands[i], config, configDir, dotenv)
+		if missing, ok := err.(*varError); ok {
+			missing.path = fmt.Sprintf("commands.%d.%s", i, missing.Field)
 		}
+		if err != nil {
+			return err
+		}
+	}
 
-		// Create command-specific environment by merging global env with command env
-		// Command env has higher priority
-		commandEnv := make(map[string]string)
-		for k, v := range envVars {
-			commandEnv[k] = v
+	return nil
+}
+
+// processCommandEnv applies the command's env files and expands environment
+// variables in its fields and in those of its inline steps
+func processCommandEnv(cmd *CommandConfig, config *ConfigFile, configDir string, dotenv map[string]string) error {
+	// Command-level env files override the file-level .env variables
+	commandDotenv := dotenv
+	if len(cmd.EnvFiles) > 0 {
+		commandEnvVars, err := loadEnvFiles(configDir, cmd.EnvFiles, withSystemEnv(dotenv))
+		if err != nil {
+			return fmt.Errorf("command %q: %w", cmd.Name, err)
+		}
+		commandDotenv = make(map[string]string)
+		for k, v := range dotenv {
+			commandDotenv[k] = v
 		}
-		for k, v := range expandedEnv {
-			commandEnv[k] = v
+		for k, v := range commandEnvVars {
+			commandDotenv[k] = v
 		}
+	}
+	envVars := withSystemEnv(commandDotenv)
 
-		// Now expand command fields using the merged environment
-		config.Commands[i].Command = ExpandEnvVars(config.Commands[i].Command, commandEnv)
+	// Export the resolved .env variables to the child process if requested
+	export := config.ExportDotenv != nil && *config.ExportDotenv
+	if cmd.ExportDotenv != nil {
+		export = *cmd.ExportDotenv
+	}
+	if export {
+		cmd.dotenv = commandDotenv
+	}
 
-		// Expand args
-		for j := range config.Commands[i].Args {
-			config.Commands[i].Args[j] = ExpandEnvVars(config.Commands[i].Args[j], commandEnv)
+	// Remember references that cannot be resolved, for `seli validate`, and
+	// stop at the first required variable that is not set
+	cmd.undefinedVars = nil
+	var missing *varError
+	expandField := func(field, value string, vars map[string]string, shell bool) string {
+		expandFunc := expandVars
+		if shell {
+			expandFunc = expandShellVars
 		}
+		expanded, undefined, err := expandFunc(value, vars)
+		for _, name := range undefined {
+			cmd.undefinedVars = append(cmd.undefinedVars, undefinedVar{Field: field, Name: name})
+		}
+		if err != nil && missing == nil {
+			err.Command, err.Field = cmd.Name, field
+			missing = err
+		}
+		return expanded
+	}
+	expand := func(field, value string, vars map[string]string) string {
+		return expandField(field, value, vars, false)
+	}
+	// Text run by a shell leaves bare $VAR references to the shell
+	expandShell := func(field, value string, vars map[string]string) string {
+		return expandField(field, value, vars, true)
+	}
+	commandExpand := expand
+	if cmd.Shell.Enabled() {
+		commandExpand = expandShell
+	}
+
+	// First, expand env values using global environment variables. The
+	// active profile's env applies to every command, over the env inherited
+	// from defaults.
+	cmd.Env = layeredEnv(config, cmd)
+	expandedEnv := make(map[string]string)
+	for k, v := range cmd.Env {
+		expandedEnv[k] = expand("env."+k, v, envVars)
+	}
+
+	// Create command-specific environment by merging global env with command env
+	// Command env has higher priority
+	commandEnv := make(map[string]string)
+	for k, v := range envVars {
+		commandEnv[k] = v
+	}
+	for k, v := range expandedEnv {
+		commandEnv[k] = v
+	}
+
+	// Now expand command fields using the merged environment. A command line
+	// run without a shell is split into words as its references are
+	// expanded, so that a value with spaces or quotes stays one argument.
+	// An unterminated quote is reported by checkCommandLine.
+	commandLine := cmd.Script == "" && !cmd.Shell.Enabled() && len(cmd.Args) == 0
+	if _, err := SplitCommandLine(cmd.Command); commandLine && err == nil && strings.Contains(cmd.Command, "$") {
+		words, _ := splitCommandWords(cmd.Command, func(ref string) string {
+			return expand("command", ref, commandEnv)
+		})
+		cmd.Command = JoinCommandLine(words)
+	} else {
+		cmd.Command = commandExpand("command", cmd.Command, commandEnv)
+	}
+	cmd.Script = expandShell("script", cmd.Script, commandEnv)
 
-		// Update env with expanded values
-		config.Commands[i].Env = expandedEnv
+	// Expand args, which are positional parameters rather than shell text
+	// in a script
+	argsExpand := commandExpand
+	if cmd.Script != "" {
+		argsExpand = expand
+	}
+	for j := range cmd.Args {
+		cmd.Args[j] = argsExpand(fmt.Sprintf("args.%d", j), cmd.Args[j], commandEnv)
+	}
+
+	// Update env with expanded values
+	cmd.Env = expandedEnv
+
+	// Expand workDir
+	cmd.WorkDir = expand("workDir", cmd.WorkDir, commandEnv)
+	if missing != nil {
+		return missing
+	}
 
-		// Expand workDir
-		config.Commands[i].WorkDir = ExpandEnvVars(config.Commands[i].WorkDir, commandEnv)
+	for _, group := range []struct {
+		key   string
+		steps []StepConfig
+	}{{"dependsOn", cmd.DependsOn}, {"steps", cmd.Steps}, {"parallel", cmd.Parallel}} {
+		for i := range group.steps {
+			if group.steps[i].Ref == "" {
+				// Inline steps see the .env variables of the command they belong to
+				err := processCommandEnv(&group.steps[i].CommandConfig, config, configDir, commandDotenv)
+				if missing, ok := err.(*varError); ok {
+					missing.Command = cmd.Name
+					missing.Field = fmt.Sprintf("%s.%d.%s", group.key, i, missing.Field)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes content to dir/name, creating parent directories
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", name, err)
+	}
+	return path
+}
+
+// environMap converts a KEY=VALUE list to a map, later entries winning
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, entry := range environ {
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+func TestExportDotenvPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LAYER_SYSTEM", "system")
+	t.Setenv("LAYER_PARENT", "system")
+
+	configDir := filepath.Join(home, ".seli", "ops")
+	writeTestFile(t, home, ".seli/.env", "LAYER_PARENT=parent\nLAYER_DIR=parent\nLAYER_FILE=parent\n")
+	writeTestFile(t, configDir, ".env", "LAYER_DIR=dir\nLAYER_FILE=dir\nLAYER_CMDFILE=dir\n")
+	writeTestFile(t, configDir, ".env.shared", "LAYER_FILE=file\nLAYER_CMDFILE=file\nLAYER_CMD=file\n")
+	writeTestFile(t, configDir, ".env.command", "LAYER_CMDFILE=command-file\nLAYER_CMD=command-file\n")
+	path := writeTestFile(t, configDir, "deploy.yml", `name: Deploy
+exportDotenv: true
+envFiles: [".env.shared"]
+commands:
+  - name: Layered
+    command: env
+    envFiles: [".env.command"]
+    env:
+      LAYER_CMD: command
+  - name: Plain
+    command: env
+  - name: Opted Out
+    command: env
+    exportDotenv: false
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	env := environMap(commandEnviron(config.Commands[0]))
+	expected := map[string]string{
+		"LAYER_SYSTEM":  "system",
+		"LAYER_PARENT":  "parent",
+		"LAYER_DIR":     "dir",
+		"LAYER_FILE":    "file",
+		"LAYER_CMDFILE": "command-file",
+		"LAYER_CMD":     "command",
+	}
+	for key, value := range expected {
+		if env[key] != value {
+			t.Errorf("%s = %q, want %q", key, env[key], value)
+		}
+	}
+
+	plain := environMap(commandEnviron(config.Commands[1]))
+	if plain["LAYER_CMDFILE"] != "file" {
+		t.Errorf("Command env files should not leak to other commands, LAYER_CMDFILE = %q", plain["LAYER_CMDFILE"])
+	}
+
+	if environ := commandEnviron(config.Commands[2]); environ != nil {
+		t.Errorf("Expected command with exportDotenv: false to inherit the environment, got %d variables", len(environ))
+	}
+}
+
+func TestDotenvNotExportedByDefault(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, ".env", "TEST_ENV_A=Apple\n")
+	path := writeTestFile(t, configDir, "fruits.yml", `name: Fruits
+commands:
+  - name: Show Fruit A
+    command: echo
+    args: ["${TEST_ENV_A}"]
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	if config.Commands[0].Args[0] != "Apple" {
+		t.Errorf("Expected .env value to be used for expansion, got %q", config.Commands[0].Args[0])
+	}
+	if environ := commandEnviron(config.Commands[0]); environ != nil {
+		t.Errorf("Expected .env variables not to be exported by default, got %d variables", len(environ))
+	}
+}
+
+func TestMissingEnvFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "broken.yml", `name: Broken
+envFiles: [".env.missing"]
+commands: []
+`)
+
+	if _, err := LoadConfigFile(path, ""); err == nil {
+		t.Error("Expected error for missing env file")
+	}
+}
+
+func TestShellSpecDecoding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	files := map[string]string{
+		"shell.yml": `name: Shell
+shell: bash
+commands:
+  - name: Inherited
+    command: echo $(date)
+  - name: Default
+    command: echo
+    shell: true
+  - name: Disabled
+    command: echo
+    shell: false
+`,
+		"shell.json": `{"name": "Shell", "shell": "bash", "commands": [
+  {"name": "Inherited", "command": "echo $(date)"},
+  {"name": "Default", "command": "echo", "shell": true},
+  {"name": "Disabled", "command": "echo", "shell": false}
+]}`,
+		"shell.toml": `name = "Shell"
+shell = "bash"
+
+[[commands]]
+name = "Inherited"
+command = "echo $(date)"
+
+[[commands]]
+name = "Default"
+command = "echo"
+shell = true
+
+[[commands]]
+name = "Disabled"
+command = "echo"
+shell = false
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfigFile(writeTestFile(t, configDir, name, content), "")
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+
+			expected := []ShellSpec{"bash", shellDefault, shellOff}
+			for i, shell := range expected {
+				if config.Commands[i].Shell != shell {
+					t.Errorf("command %q shell = %q, want %q", config.Commands[i].Name, config.Commands[i].Shell, shell)
+				}
+			}
+			if config.Commands[2].Shell.Enabled() {
+				t.Error("Expected `shell: false` to disable the shell")
+			}
+		})
+	}
+}
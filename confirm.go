@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfirmSpec asks for confirmation before a command runs. Config files write
+// it either as a boolean (`confirm: true` asks "Run ...? (y/N)"), as a prompt,
+// in which case the command name has to be typed to continue, or as an object
+// with a prompt and the answer that has to be typed.
+type ConfirmSpec struct {
+	// given is true when confirm was written at all, off when it was false
+	given, off bool
+	prompt     string
+	answer     string
+}
+
+var (
+	confirmOff     = ConfirmSpec{given: true, off: true}
+	confirmDefault = ConfirmSpec{given: true}
+)
+
+// IsSet reports whether the command sets confirm itself
+func (c ConfirmSpec) IsSet() bool {
+	return c.given
+}
+
+// Enabled reports whether the command asks for confirmation
+func (c ConfirmSpec) Enabled() bool {
+	return c.given && !c.off
+}
+
+// Typed reports whether an answer has to be typed to confirm
+func (c ConfirmSpec) Typed() bool {
+	return c.Enabled() && (c.prompt != "" || c.answer != "")
+}
+
+// expected returns the answer that confirms cmd: the configured one, or the
+// command name
+func (c ConfirmSpec) expected(cmd CommandConfig) string {
+	if c.answer != "" {
+		return c.answer
+	}
+	return cmd.Name
+}
+
+// String returns the setting as written in a config file, for `seli explain`
+func (c ConfirmSpec) String() string {
+	switch {
+	case !c.given:
+		return ""
+	case c.off:
+		return "false"
+	case c.answer != "":
+		return fmt.Sprintf("%s (answer: %s)", c.prompt, c.answer)
+	case c.prompt != "":
+		return c.prompt
+	}
+	return "true"
+}
+
+// set stores a decoded bool, string or object value
+func (c *ConfirmSpec) set(value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		*c = confirmOff
+		if v {
+			*c = confirmDefault
+		}
+	case string:
+		*c = ConfirmSpec{given: true, prompt: strings.TrimSpace(v)}
+	case map[string]interface{}:
+		spec := ConfirmSpec{given: true}
+		for key, field := range v {
+			text, ok := field.(string)
+			if !ok {
+				return fmt.Errorf("confirm %s must be a string, got %T", key, field)
+			}
+			switch key {
+			case "prompt":
+				spec.prompt = strings.TrimSpace(text)
+			case "answer":
+				spec.answer = strings.TrimSpace(text)
+			default:
+				return fmt.Errorf("unknown confirm key %q, expected prompt or answer", key)
+			}
+		}
+		if spec.answer == "" {
+			return fmt.Errorf("confirm needs an answer when written as an object")
+		}
+		*c = spec
+	default:
+		return fmt.Errorf("confirm must be a boolean, a prompt or an object with prompt and answer, got %T", value)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts a boolean, a string or an object
+func (c *ConfirmSpec) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return c.set(value)
+}
+
+// MarshalJSON writes the setting back in the form it was written in
+func (c ConfirmSpec) MarshalJSON() ([]byte, error) {
+	switch {
+	case !c.given:
+		return []byte("null"), nil
+	case c.off:
+		return []byte("false"), nil
+	case c.answer != "":
+		return json.Marshal(map[string]string{"prompt": c.prompt, "answer": c.answer})
+	case c.prompt != "":
+		return json.Marshal(c.prompt)
+	}
+	return []byte("true"), nil
+}
+
+// UnmarshalYAML accepts a boolean, a string or an object
+func (c *ConfirmSpec) UnmarshalYAML(node *yaml.Node) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return c.set(value)
+}
+
+// UnmarshalTOML accepts a boolean, a string or a table
+func (c *ConfirmSpec) UnmarshalTOML(value interface{}) error {
+	return c.set(value)
+}
+
+// commandConfirm returns how cmd is confirmed. Commands of a dangerous file
+// ask for confirmation unless they set `confirm: false`.
+func commandConfirm(config *ConfigFile, cmd CommandConfig) ConfirmSpec {
+	if !cmd.Confirm.IsSet() && config != nil && config.Dangerous {
+		return confirmDefault
+	}
+	return cmd.Confirm
+}
+
+// confirmStep is a command that asks for confirmation before it runs
+type confirmStep struct {
+	spec    ConfirmSpec
+	command CommandConfig
+}
+
+// planConfirms returns the commands that ask for confirmation when cmd runs:
+// cmd itself and the dependencies, steps and parallel commands it runs, each
+// once and in the order they are found. Inline steps are covered by the
+// confirmation of their command unless they set confirm themselves.
+func planConfirms(config *ConfigFile, cmd CommandConfig) ([]confirmStep, error) {
+	if config == nil {
+		if cmd.Confirm.Enabled() {
+			return []confirmStep{{spec: cmd.Confirm, command: cmd}}, nil
+		}
+		return nil, nil
+	}
+	if _, err := buildPlan(config, cmd); err != nil {
+		return nil, err
+	}
+
+	p := &planner{configs: map[string]*ConfigFile{config.Path: config}}
+	seen := make(map[string]bool)
+	var confirms []confirmStep
+	var walk func(config *ConfigFile, cmd CommandConfig, inline bool) error
+	walk = func(config *ConfigFile, cmd CommandConfig, inline bool) error {
+		if !inline {
+			key := cmd.stepsConfig(config).Path + "#" + cmd.Name
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+
+		spec := cmd.Confirm
+		if !inline {
+			spec = commandConfirm(config, cmd)
+		}
+		if spec.Enabled() {
+			confirms = append(confirms, confirmStep{spec: spec, command: cmd})
+		}
+
+		config = cmd.stepsConfig(config)
+
+		for _, step := range cmd.allSteps() {
+			stepConfig, stepCmd, err := p.resolve(config, step)
+			if err != nil {
+				return fmt.Errorf("command %q: step %q: %w", cmd.Name, step.label(), err)
+			}
+			if err := walk(stepConfig, stepCmd, step.Ref == ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(config, cmd, false); err != nil {
+		return nil, err
+	}
+	return confirms, nil
+}
+
+// confirmQuestion returns the question asked before cmd runs
+func confirmQuestion(spec ConfirmSpec, cmd CommandConfig) string {
+	switch {
+	case spec.answer != "" && spec.prompt != "":
+		// The prompt says what to type
+		return spec.prompt
+	case spec.Typed():
+		question := fmt.Sprintf("Type %q to continue:", spec.expected(cmd))
+		if spec.prompt != "" {
+			question = spec.prompt + "\n" + question
+		}
+		return question
+	}
+	return fmt.Sprintf("Run %q? (y/N)", cmd.Name)
+}
+
+// confirmAnswer reports whether answer confirms cmd
+func confirmAnswer(spec ConfirmSpec, cmd CommandConfig, answer string) bool {
+	answer = strings.TrimSpace(answer)
+	if spec.Typed() {
+		return answer == spec.expected(cmd)
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// askConfirm asks the question for cmd on w and reads the answer from r
+func askConfirm(r io.Reader, w io.Writer, spec ConfirmSpec, cmd CommandConfig) bool {
+	fmt.Fprintf(w, "%s ", confirmQuestion(spec, cmd))
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	return confirmAnswer(spec, cmd, answer)
+}
+
+// confirmCLI asks on the terminal for confirmation of cmd and of every
+// command it runs that needs it, unless yes is set. Without a terminal to ask
+// on, the command is refused.
+func confirmCLI(config *ConfigFile, cmd CommandConfig, yes bool) error {
+	if yes {
+		return nil
+	}
+	confirms, err := planConfirms(config, cmd)
+	if err != nil {
+		return err
+	}
+	for _, confirm := range confirms {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fmt.Errorf("command %q needs confirmation; pass --yes to run it", confirm.command.Name)
+		}
+		if !askConfirm(os.Stdin, os.Stderr, confirm.spec, confirm.command) {
+			return fmt.Errorf("command %q was not confirmed", confirm.command.Name)
+		}
+	}
+	return nil
+}
+
+// confirmDialog is the modal shown before a command that needs confirmation.
+// It asks for each command of confirms in turn, then runs run.
+type confirmDialog struct {
+	spec     ConfirmSpec
+	command  CommandConfig
+	confirms []confirmStep
+	run      CommandConfig
+	input    textinput.Model
+	returnTo state
+}
+
+// openConfirm shows the confirmation modal for the first of confirms, which
+// run needs before it runs
+func (m Model) openConfirm(confirms []confirmStep, run CommandConfig) (Model, tea.Cmd) {
+	returnTo := m.state
+	if m.state == stateCollectingParams {
+		returnTo = m.formReturn
+	}
+	if m.state == stateConfirming {
+		returnTo = m.confirm.returnTo
+	}
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Focus()
+	m.confirm = confirmDialog{
+		spec:     confirms[0].spec,
+		command:  confirms[0].command,
+		confirms: confirms[1:],
+		run:      run,
+		input:    input,
+		returnTo: returnTo,
+	}
+	m.state = stateConfirming
+	return m, textinput.Blink
+}
+
+// confirmed asks for the next command of the open modal, or runs the
+// command once every one was confirmed
+func (m Model) confirmed() (Model, tea.Cmd) {
+	dialog := m.confirm
+	if len(dialog.confirms) > 0 {
+		return m.openConfirm(dialog.confirms, dialog.run)
+	}
+	m.state = dialog.returnTo
+	return m.runSelected(dialog.run)
+}
+
+// updateConfirm handles keys while the confirmation modal is shown
+func (m Model) updateConfirm(msg tea.Msg) (Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.confirm.input, cmd = m.confirm.input.Update(msg)
+		return m, cmd
+	}
+
+	dialog := m.confirm
+	switch key.Type {
+	case tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.state = dialog.returnTo
+		m.background = false
+		return m, nil
+	}
+
+	if dialog.spec.Typed() {
+		if key.Type == tea.KeyEnter {
+			if !confirmAnswer(dialog.spec, dialog.command, dialog.input.Value()) {
+				return m, nil
+			}
+			return m.confirmed()
+		}
+		var cmd tea.Cmd
+		m.confirm.input, cmd = dialog.input.Update(msg)
+		return m, cmd
+	}
+
+	if key.Type == tea.KeyRunes && confirmAnswer(dialog.spec, dialog.command, string(key.Runes)) {
+		return m.confirmed()
+	}
+	// Anything else, including Enter, cancels
+	m.state = dialog.returnTo
+	m.background = false
+	return m, nil
+}
+
+// confirmView renders the confirmation modal
+func (m Model) confirmView() string {
+	dialog := m.confirm
+	content := dangerStyle.Render("⚠ " + dialog.command.Name)
+	content += "\n\n" + confirmQuestion(dialog.spec, dialog.command)
+	if dialog.spec.Typed() {
+		content += "\n" + dialog.input.View()
+	}
+	content += "\n\n" + lipgloss.NewStyle().Faint(true).Render("esc cancel")
+
+	box := confirmStyle.Render(content)
+	if m.width == 0 || m.height == 0 {
+		return box
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+func TestConfirmDecoding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	files := map[string]string{
+		"ops.yml": `name: Ops
+dangerous: true
+commands:
+  - name: Drop
+    command: dropdb app
+    confirm: Type the command name to continue
+  - name: Status
+    command: pg_isready
+    confirm: false
+  - name: Stop
+    command: docker stop app
+  - name: Deploy
+    command: deploy prod
+    confirm:
+      prompt: Type the env name to continue
+      answer: prod
+`,
+		"ops.json": `{"name": "Ops", "dangerous": true, "commands": [
+  {"name": "Drop", "command": "dropdb app", "confirm": "Type the command name to continue"},
+  {"name": "Status", "command": "pg_isready", "confirm": false},
+  {"name": "Stop", "command": "docker stop app"},
+  {"name": "Deploy", "command": "deploy prod", "confirm": {"prompt": "Type the env name to continue", "answer": "prod"}}
+]}`,
+		"ops.toml": `name = "Ops"
+dangerous = true
+
+[[commands]]
+name = "Drop"
+command = "dropdb app"
+confirm = "Type the command name to continue"
+
+[[commands]]
+name = "Status"
+command = "pg_isready"
+confirm = false
+
+[[commands]]
+name = "Stop"
+command = "docker stop app"
+
+[[commands]]
+name = "Deploy"
+command = "deploy prod"
+confirm = { prompt = "Type the env name to continue", answer = "prod" }
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadConfigFile(writeTestFile(t, configDir, name, content), "")
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+			if spec := commandConfirm(config, config.Commands[0]); !spec.Typed() {
+				t.Errorf("Expected a typed confirmation for Drop, got %q", spec)
+			}
+			if spec := commandConfirm(config, config.Commands[1]); spec.Enabled() {
+				t.Errorf("Expected confirm: false to opt out of the dangerous file, got %q", spec)
+			}
+			if spec := commandConfirm(config, config.Commands[2]); spec != confirmDefault {
+				t.Errorf("Expected Stop to be confirmed because the file is dangerous, got %q", spec)
+			}
+			deploy := config.Commands[3]
+			if spec := commandConfirm(config, deploy); confirmQuestion(spec, deploy) != "Type the env name to continue" || !confirmAnswer(spec, deploy, "prod") {
+				t.Errorf("Expected Deploy to ask for the configured answer, got %q", spec)
+			}
+		})
+	}
+}
+
+func TestConfirmAnswer(t *testing.T) {
+	cmd := CommandConfig{Name: "Drop database"}
+	tests := []struct {
+		spec   ConfirmSpec
+		answer string
+		want   bool
+	}{
+		{confirmDefault, "y", true},
+		{confirmDefault, "YES\n", true},
+		{confirmDefault, "", false},
+		{confirmDefault, "n", false},
+		{ConfirmSpec{given: true, prompt: "Type the name"}, "Drop database\n", true},
+		{ConfirmSpec{given: true, prompt: "Type the name"}, "y", false},
+		{ConfirmSpec{given: true, prompt: "Type the name"}, "drop database", false},
+		{ConfirmSpec{given: true, prompt: "Type the env", answer: "prod"}, "prod\n", true},
+		{ConfirmSpec{given: true, prompt: "Type the env", answer: "prod"}, "Drop database", false},
+		{ConfirmSpec{given: true, prompt: "Type the env", answer: "prod"}, "staging", false},
+	}
+
+	for _, tt := range tests {
+		if got := confirmAnswer(tt.spec, cmd, tt.answer); got != tt.want {
+			t.Errorf("confirmAnswer(%q, %q) = %v, want %v", tt.spec, tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmModal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFile(t, filepath.Join(home, ".seli"), "ops.yml", `name: Ops
+commands:
+  - name: Stop
+    command: docker stop app
+    confirm: true
+  - name: Drop
+    command: dropdb app
+    confirm: This deletes every row
+`)
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	typeText := func(text string) {
+		for _, r := range text {
+			send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+
+	if !model.list.SelectedItem().(Item).dangerous {
+		t.Error("Expected commands that need confirmation to be marked in the list")
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateConfirming || !strings.Contains(model.View(), `Run "Stop"? (y/N)`) {
+		t.Fatalf("Expected the confirmation modal, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if model.state != stateViewingCommands || model.pending != nil {
+		t.Fatalf("Expected n to cancel, got state %v", model.state)
+	}
+
+	model.list.Select(1)
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if !strings.Contains(model.View(), "This deletes every row") {
+		t.Errorf("Expected the custom prompt, got %q", model.View())
+	}
+	typeText("y")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateConfirming {
+		t.Fatalf("Expected a wrong answer to keep the modal open, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyBackspace})
+	typeText("Drop")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateExecutingCommand || model.pending.Name != "Drop" {
+		t.Errorf("Expected Drop to execute after typing its name, got state %v", model.state)
+	}
+}
+
+func TestConfirmCLIWithoutTerminal(t *testing.T) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		t.Skip("stdin is a terminal")
+	}
+	config := &ConfigFile{Name: "Ops", Dangerous: true}
+	cmd := CommandConfig{Name: "Stop", Command: "docker stop app"}
+
+	if err := confirmCLI(config, cmd, false); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("Expected the command to be refused without --yes, got %v", err)
+	}
+	if err := confirmCLI(config, cmd, true); err != nil {
+		t.Errorf("Expected --yes to skip the confirmation, got %v", err)
+	}
+}
+
+func TestStepsAreConfirmed(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "db.yml", `name: DB
+commands:
+  - name: Reset
+    steps: [Drop, Seed, Drop]
+  - name: Drop
+    command: dropdb app
+    confirm: true
+  - name: Seed
+    command: seed app
+  - name: Both
+    parallel: [Seed, Wipe]
+  - name: Wipe
+    command: wipe app
+    confirm: Type the command name to continue
+  - name: Setup
+    dependsOn: [Reset]
+    steps:
+      - command: migrate
+      - command: truncate
+        confirm: true
+`)
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	for name, want := range map[string]string{"Reset": "Drop", "Both": "Wipe", "Setup": "Drop, Setup step 2", "Seed": ""} {
+		confirms, err := planConfirms(config, *findStepCommand(config, name))
+		if err != nil {
+			t.Fatalf("planConfirms(%s) error = %v", name, err)
+		}
+		var names []string
+		for _, confirm := range confirms {
+			names = append(names, confirm.command.Name)
+		}
+		if got := strings.Join(names, ", "); got != want {
+			t.Errorf("planConfirms(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		if err := confirmCLI(config, *findStepCommand(config, "Reset"), false); err == nil || !strings.Contains(err.Error(), `"Drop" needs confirmation`) {
+			t.Errorf("Expected Reset to be refused for its Drop step, got %v", err)
+		}
+	}
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateConfirming || !strings.Contains(model.View(), `Run "Drop"? (y/N)`) {
+		t.Fatalf("Expected Reset to ask for its Drop step, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if model.state != stateExecutingCommand || model.pending.Name != "Reset" {
+		t.Errorf("Expected Reset to run once Drop was confirmed, got state %v", model.state)
+	}
+}
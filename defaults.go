@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultsFileNames are the files whose settings apply to every config file
+// in their directory and below
+var defaultsFileNames = []string{"_defaults.yml", "_defaults.yaml", "_defaults.json", "_defaults.toml"}
+
+// DefaultsConfig holds the settings that commands inherit when they do not set
+// them themselves
+type DefaultsConfig struct {
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	WorkDir string            `json:"workDir,omitempty" yaml:"workDir,omitempty" toml:"workDir,omitempty"`
+	Shell   ShellSpec         `json:"shell,omitempty" yaml:"shell,omitempty" toml:"shell,omitempty"`
+	Timeout string            `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Show    *bool             `json:"show,omitempty" yaml:"show,omitempty" toml:"show,omitempty"`
+	Confirm ConfirmSpec       `json:"confirm,omitempty" yaml:"confirm,omitempty" toml:"confirm,omitempty"`
+}
+
+// defaultsLayer is one source of defaults
+type defaultsLayer struct {
+	// source names the layer in `seli explain`
+	source   string
+	defaults DefaultsConfig
+}
+
+// isDefaultsFile reports whether name is a _defaults file rather than a
+// config file
+func isDefaultsFile(name string) bool {
+	base := filepath.Base(name)
+	for _, defaultsName := range defaultsFileNames {
+		if strings.EqualFold(base, defaultsName) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTimeout reports a timeout that is not a positive duration
+func checkTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q: expected a duration such as 30s or 5m", timeout)
+	}
+	return nil
+}
+
+// timeout returns the timeout of the command, or 0 when it has none
+func (c CommandConfig) timeout() time.Duration {
+	d, _ := time.ParseDuration(c.Timeout)
+	return d
+}
+
+// setOrigin records where the inherited setting field comes from
+func (c *CommandConfig) setOrigin(field, source string) {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[field] = source
+}
+
+// defaultsLayers returns the defaults of the config file at path, most
+// specific first: its defaults section, its file-level shell and show, then
+// the _defaults files of its directory and of each parent directory up to
+// rootDir, the directory of the config root it was found in. Without a
+// rootDir that contains the file, the search stops at a .seli directory, a
+// repository root or the home directory.
+func defaultsLayers(config *ConfigFile, rootDir, path, ext string, data []byte, strict bool) ([]defaultsLayer, error) {
+	var layers []defaultsLayer
+	if config.Defaults != nil {
+		if err := checkTimeout(config.Defaults.Timeout); err != nil {
+			pos, _ := configPositions(ext, data)
+			return nil, newConfigError(path, data, pos.find("defaults.timeout"), err)
+		}
+		layers = append(layers, defaultsLayer{source: "defaults", defaults: *config.Defaults})
+	}
+	layers = append(layers, defaultsLayer{source: "file", defaults: DefaultsConfig{Shell: config.Shell, Show: config.Show}})
+
+	homeDir, _ := os.UserHomeDir()
+	if rootDir != "" && !isWithin(rootDir, config.Path) {
+		rootDir = ""
+	}
+	for dir := filepath.Dir(config.Path); ; dir = filepath.Dir(dir) {
+		for _, name := range defaultsFileNames {
+			defaultsPath := filepath.Join(dir, name)
+			if _, err := os.Stat(defaultsPath); err != nil {
+				continue
+			}
+			defaults, err := loadDefaultsFile(defaultsPath, strict)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, defaultsLayer{source: displayPath(defaultsPath), defaults: *defaults})
+		}
+		if dir == rootDir || rootDir == "" && isConfigRootDir(dir, homeDir) || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return layers, nil
+}
+
+// isConfigRootDir reports whether the search for _defaults files of a config
+// file outside the config roots stops at dir: a .seli directory, a repository
+// root or the home directory
+func isConfigRootDir(dir, homeDir string) bool {
+	if filepath.Base(dir) == ".seli" || dir == homeDir {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// loadDefaultsFile loads a _defaults file, ignoring unknown keys unless
+// strict is set
+func loadDefaultsFile(path string, strict bool) (*DefaultsConfig, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	var defaults DefaultsConfig
+	if err := decodeConfig(ext, data, &defaults, strict); err != nil {
+		return nil, decodeError(path, ext, data, &defaults, err)
+	}
+	if err := checkTimeout(defaults.Timeout); err != nil {
+		pos, _ := configPositions(ext, data)
+		return nil, newConfigError(path, data, pos.find("timeout"), err)
+	}
+	return &defaults, nil
+}
+
+// applyDefaults fills in the settings that cmd and its inline steps do not
+// set from the first layer that does. The env of the layers is kept apart in
+// defaultEnv, so that the active profile can override it.
+func applyDefaults(cmd *CommandConfig, layers []defaultsLayer) {
+	for _, layer := range layers {
+		d := layer.defaults
+		if cmd.WorkDir == "" && d.WorkDir != "" {
+			cmd.WorkDir = d.WorkDir
+			cmd.setOrigin("workDir", layer.source)
+		}
+		if cmd.Shell == "" && d.Shell != "" {
+			cmd.Shell = d.Shell
+			cmd.setOrigin("shell", layer.source)
+		}
+		if cmd.Timeout == "" && d.Timeout != "" {
+			cmd.Timeout = d.Timeout
+			cmd.setOrigin("timeout", layer.source)
+		}
+		if cmd.Show == nil && d.Show != nil {
+			cmd.Show = d.Show
+			cmd.setOrigin("show", layer.source)
+		}
+		if !cmd.Confirm.IsSet() && d.Confirm.IsSet() {
+			cmd.Confirm = d.Confirm
+			cmd.setOrigin("confirm", layer.source)
+		}
+		for k, v := range d.Env {
+			if _, own := cmd.Env[k]; own {
+				continue
+			}
+			if _, inherited := cmd.defaultEnv[k]; inherited {
+				continue
+			}
+			if cmd.defaultEnv == nil {
+				cmd.defaultEnv = make(map[string]string)
+			}
+			cmd.defaultEnv[k] = v
+			cmd.setOrigin("env."+k, layer.source)
+		}
+	}
+
+	for _, steps := range [][]StepConfig{cmd.DependsOn, cmd.Steps, cmd.Parallel} {
+		for i := range steps {
+			if steps[i].Ref == "" {
+				applyDefaults(&steps[i].CommandConfig, layers)
+			}
+		}
+	}
+}
+
+// explainCommand implements `seli explain <file> <command>`
+func explainCommand(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli explain <file> <command>")
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	roots, err := ConfigRoots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	path, err := resolveRootConfigPath(roots, positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	config, err := LoadConfigFile(path, rootDirOf(roots, path))
+	if err == nil {
+		err = checkProfile(config)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	command, err := findCommand(config, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	writeExplanation(os.Stdout, config, *command)
+	return 0
+}
+
+// writeExplanation prints the effective settings of cmd, one per line, with
+// where each comes from: the command itself, the file's defaults or
+// file-level keys, a _defaults file or the active profile. Values of secret
+// variables are masked.
+func writeExplanation(w io.Writer, config *ConfigFile, cmd CommandConfig) {
+	fmt.Fprintf(w, "%s (%s)\n", cmd.Name, displayPath(config.Path))
+	if config.profile != "" {
+		fmt.Fprintf(w, "profile: %s\n", config.profile)
+	}
+	fmt.Fprintln(w)
+
+	secrets := secretValues(cmd)
+	var rows [][3]string
+	add := func(field, value string) {
+		source := cmd.origins[field]
+		switch {
+		case value == "":
+			value, source = "-", ""
+		case source == "":
+			source = "command"
+		}
+		rows = append(rows, [3]string{field, maskSecrets(value, secrets), source})
+	}
+
+	switch {
+	case cmd.isChain() || len(cmd.Parallel) > 0:
+	case cmd.Script != "":
+		lines := strings.Split(strings.TrimSpace(cmd.Script), "\n")
+		if len(lines) > 1 {
+			lines[0] += fmt.Sprintf(" (+%d lines)", len(lines)-1)
+		}
+		add("script", lines[0])
+	default:
+		if argv, err := commandArgv(cmd); err == nil {
+			add("command", JoinCommandLine(argv))
+		}
+	}
+	add("workDir", commandDir(cmd))
+	add("shell", string(cmd.Shell))
+	add("timeout", cmd.Timeout)
+	show := ""
+	if cmd.Show != nil {
+		show = strconv.FormatBool(*cmd.Show)
+	}
+	add("show", show)
+	if !cmd.Confirm.IsSet() && config.Dangerous {
+		rows = append(rows, [3]string{"confirm", confirmDefault.String(), "dangerous"})
+	} else {
+		add("confirm", cmd.Confirm.String())
+	}
+
+	names := make([]string, 0, len(cmd.Env))
+	for name := range cmd.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := cmd.Env[name]
+		if value == "" {
+			value = `""`
+		}
+		add("env."+name, value)
+	}
+
+	widths := [2]int{}
+	for _, row := range rows {
+		for i := range widths {
+			if len(row[i]) > widths[i] {
+				widths[i] = len(row[i])
+			}
+		}
+	}
+	for _, row := range rows {
+		line := fmt.Sprintf("  %-*s  %-*s  %s", widths[0], row[0], widths[1], row[1], row[2])
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
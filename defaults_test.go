@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const defaultsConfig = `name: API
+shell: sh
+defaults:
+  env:
+    REGION: eu-west-1
+  show: true
+profiles:
+  prod:
+    env:
+      REGION: us-east-1
+commands:
+  - name: Deploy
+    command: deploy --region ${REGION} --log ${LOG}
+  - name: Local
+    command: serve
+    workDir: /srv/local
+    shell: bash
+    timeout: 10s
+    env:
+      LOG: trace
+`
+
+func TestDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, "_defaults.yml", "env:\n  LOG: info\n  TEAM: ops\nworkDir: /srv\ntimeout: 5m\nconfirm: true\n")
+	writeTestFile(t, filepath.Join(configDir, "ops"), "_defaults.yml", "env:\n  LOG: debug\nshell: bash\n")
+	path := writeTestFile(t, filepath.Join(configDir, "ops"), "api.yml", defaultsConfig)
+	setProfile(t, "")
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	deploy := config.Commands[0]
+	if deploy.Command != "deploy --region eu-west-1 --log debug" {
+		t.Errorf("Command = %q, want the inherited env expanded", deploy.Command)
+	}
+	tests := []struct {
+		field, value, source string
+	}{
+		{"workDir", deploy.WorkDir, "/srv ~/.seli/_defaults.yml"},
+		{"shell", string(deploy.Shell), "sh file"},
+		{"timeout", deploy.Timeout, "5m ~/.seli/_defaults.yml"},
+		{"confirm", deploy.Confirm.String(), "true ~/.seli/_defaults.yml"},
+		{"env.LOG", deploy.Env["LOG"], "debug ~/.seli/ops/_defaults.yml"},
+		{"env.TEAM", deploy.Env["TEAM"], "ops ~/.seli/_defaults.yml"},
+		{"env.REGION", deploy.Env["REGION"], "eu-west-1 defaults"},
+	}
+	for _, tt := range tests {
+		if got := tt.value + " " + deploy.origins[tt.field]; got != tt.source {
+			t.Errorf("%s = %q, want %q", tt.field, got, tt.source)
+		}
+	}
+	if deploy.Show == nil || !*deploy.Show || deploy.origins["show"] != "defaults" {
+		t.Errorf("Expected show to come from the defaults section, got %v from %q", deploy.Show, deploy.origins["show"])
+	}
+
+	local := config.Commands[1]
+	if local.WorkDir != "/srv/local" || local.Shell != "bash" || local.Timeout != "10s" || local.Env["LOG"] != "trace" {
+		t.Errorf("Expected the command's own settings to win, got %+v", local)
+	}
+	if len(local.origins) != 4 {
+		t.Errorf("Expected only confirm, show, REGION and TEAM to be inherited, got %v", local.origins)
+	}
+
+	// The profile overrides the defaults, but not the command
+	setProfile(t, "prod")
+	config, err = LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() with a profile error = %v", err)
+	}
+	if got := config.Commands[0].Env["REGION"]; got != "us-east-1" || config.Commands[0].origins["env.REGION"] != "profile prod" {
+		t.Errorf("REGION = %q from %q, want the profile", got, config.Commands[0].origins["env.REGION"])
+	}
+
+	if IsConfigFile("_defaults.yml") || !IsConfigFile("defaults.yml") {
+		t.Error("Expected _defaults files not to be listed as config files")
+	}
+}
+
+func TestDefaultsStopAtConfigRoot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	root := filepath.Join(dir, "srv", "cmds")
+	writeTestFile(t, filepath.Join(dir, "srv"), "_defaults.yml", "workDir: /nonexistent\n")
+	writeTestFile(t, root, "_defaults.yml", "env:\n  LOG: info\n")
+	path := writeTestFile(t, root, "app.yml", "name: App\ncommands:\n  - name: Run\n    command: run\n")
+	configRootFlags = []string{root}
+	defer func() { configRootFlags = nil }()
+	roots, err := ConfigRoots()
+	if err != nil {
+		t.Fatalf("ConfigRoots() error = %v", err)
+	}
+	if got := rootDirOf(roots, path); got != root {
+		t.Fatalf("rootDirOf() = %q, want %q", got, root)
+	}
+
+	config, err := LoadConfigFile(path, root)
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if cmd := config.Commands[0]; cmd.WorkDir != "" || cmd.Env["LOG"] != "info" {
+		t.Errorf("Expected only the _defaults files within the config root, got workDir %q and env %v", cmd.WorkDir, cmd.Env)
+	}
+}
+
+func TestDefaultsErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	tests := []struct {
+		name     string
+		defaults string
+		config   string
+		file     string
+		want     string
+	}{
+		{"unknown key", "env:\n  A: b\nworkdir: /srv\n", "name: A\ncommands: []\n", "_defaults.yml", `3:1: unknown field "workdir" (did you mean "workDir"?)`},
+		{"defaults file timeout", "timeout: soon\n", "name: A\ncommands: []\n", "_defaults.yml", `1:1: invalid timeout "soon"`},
+		{"defaults section timeout", "", "name: A\ndefaults:\n  timeout: -1s\ncommands: []\n", "app.yml", `3:3: invalid timeout "-1s"`},
+		{"command timeout", "", "name: A\ncommands:\n  - name: Run\n    command: run\n    timeout: 5\n", "app.yml", `3:5: command "Run": invalid timeout "5"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(configDir, strings.ReplaceAll(tt.name, " ", "-"))
+			if tt.defaults != "" {
+				writeTestFile(t, dir, "_defaults.yml", tt.defaults)
+			}
+			path := writeTestFile(t, dir, "app.yml", tt.config)
+
+			_, err := LoadConfigFile(path, "")
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) || filepath.Base(configErr.Path) != tt.file || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfigFile() error = %v, want %s in %s", err, tt.want, tt.file)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, "_defaults.yml", "env:\n  LOG: info\n  TOKEN: s3cr3t\nworkDir: /srv\n")
+	path := writeTestFile(t, configDir, "api.yml", `name: API
+dangerous: true
+commands:
+  - name: Deploy
+    command: deploy --region eu-west-1
+    secret: [TOKEN]
+    env:
+      REGION: eu-west-1
+`)
+	setProfile(t, "")
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	var b strings.Builder
+	writeExplanation(&b, config, config.Commands[0])
+
+	want := `Deploy (~/.seli/api.yml)
+
+  command     deploy --region eu-west-1  command
+  workDir     /srv                       ~/.seli/_defaults.yml
+  shell       -
+  timeout     -
+  show        -
+  confirm     true                       dangerous
+  env.LOG     info                       ~/.seli/_defaults.yml
+  env.REGION  eu-west-1                  command
+  env.TOKEN   ****                       ~/.seli/_defaults.yml
+`
+	if b.String() != want {
+		t.Errorf("writeExplanation() =\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	start := time.Now()
+	err := NewCommandExecutor().ExecuteCommand(CommandConfig{Name: "Slow", Command: "sleep 5", Timeout: "100ms"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Fatalf("ExecuteCommand() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the command to be stopped after the timeout, took %s", elapsed)
+	}
+	if code, _ := ExitCode(err); code != 128+15 {
+		t.Errorf("ExitCode() = %d, want the SIGTERM status", code)
+	}
+}
+
+func TestTimeoutInParallelAndJobs(t *testing.T) {
+	config := &ConfigFile{Name: "Dev", Path: filepath.Join(t.TempDir(), "dev.yml")}
+	slow := StepConfig{CommandConfig: CommandConfig{Name: "Slow", Command: "sleep 5", Timeout: "100ms"}}
+	quick := StepConfig{CommandConfig: CommandConfig{Name: "Quick", Command: "true"}}
+
+	tests := []struct {
+		name  string
+		group CommandConfig
+	}{
+		{"command timeout", CommandConfig{Name: "Dev", Parallel: []StepConfig{slow, quick}}},
+		{"group timeout", CommandConfig{Name: "Dev", Timeout: "100ms", Parallel: []StepConfig{{CommandConfig: CommandConfig{Name: "Slow", Command: "sleep 5"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := NewCommandExecutor().ExecuteParallel(config, tt.group)
+			if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+				t.Errorf("ExecuteParallel() error = %v, want a timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Expected the command to be stopped after the timeout, took %s", elapsed)
+			}
+		})
+	}
+
+	jobs := NewJobManager(NewCommandExecutor())
+	defer jobs.StopAll()
+	job, err := jobs.Start(slow.CommandConfig)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job.Wait()
+	if status := job.Status(time.Now()); !strings.HasPrefix(status, "timed out") {
+		t.Errorf("Expected the job to time out, got %q", status)
+	}
+}
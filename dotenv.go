@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseEnvFile parses a .env file and returns environment variables. Values
+// may reference variables defined earlier in the file, then those of vars,
+// then the system environment. Syntax errors are *ConfigError values.
+func parseEnvFile(envFile string, vars map[string]string) (map[string]string, error) {
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		return nil, err
+	}
+	envVars, pos, err := parseDotenv(string(data), vars)
+	if err != nil {
+		return nil, newConfigError(envFile, data, pos, err)
+	}
+	return envVars, nil
+}
+
+// parseDotenv parses the contents of a .env file. It accepts the syntax of
+// dotenv and docker compose:
+//
+//	# comment
+//	export KEY=value        # an optional export prefix and inline comment
+//	KEY='literal ${NOT_EXPANDED}'
+//	KEY="escapes \n and ${OTHER:-interpolation}"
+//	KEY="values in double or single quotes
+//	may span lines"
+//
+// On error it also returns the position of the problem.
+func parseDotenv(src string, vars map[string]string) (map[string]string, position, error) {
+	p := &dotenvParser{src: src, line: 1}
+	envVars := make(map[string]string)
+	scope := make(map[string]string, len(vars))
+	for k, v := range vars {
+		scope[k] = v
+	}
+
+	for {
+		p.skipBlank()
+		if p.done() {
+			return envVars, position{}, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		start := p.pos()
+		key, raw, quote, err := p.entry()
+		if err != nil {
+			return nil, p.errPos, err
+		}
+
+		value := raw
+		if quote != '\'' {
+			expanded, _, missing := expandVars(raw, scope)
+			if missing != nil {
+				message := missing.Message
+				if message == "" {
+					message = "parameter not set"
+				}
+				return nil, start, fmt.Errorf("%s: %s: %s", key, missing.Name, message)
+			}
+			value = expanded
+		}
+		envVars[key] = value
+		scope[key] = value
+	}
+}
+
+// dotenvParser reads a .env file one entry at a time
+type dotenvParser struct {
+	src       string
+	i         int
+	line      int
+	lineStart int
+	// errPos is the position of the last error
+	errPos position
+}
+
+// done reports whether the whole input has been read
+func (p *dotenvParser) done() bool {
+	return p.i >= len(p.src)
+}
+
+// peek returns the next byte
+func (p *dotenvParser) peek() byte {
+	return p.src[p.i]
+}
+
+// advance consumes one byte, tracking lines
+func (p *dotenvParser) advance() byte {
+	c := p.src[p.i]
+	p.i++
+	if c == '\n' {
+		p.line++
+		p.lineStart = p.i
+	}
+	return c
+}
+
+// pos returns the position of the next byte
+func (p *dotenvParser) pos() position {
+	return position{Line: p.line, Column: p.i - p.lineStart + 1}
+}
+
+// fail records the current position and returns an error
+func (p *dotenvParser) fail(format string, args ...interface{}) error {
+	p.errPos = p.pos()
+	return fmt.Errorf(format, args...)
+}
+
+// skipBlank skips whitespace, including line breaks
+func (p *dotenvParser) skipBlank() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.advance()
+	}
+}
+
+// skipSpaces skips spaces and tabs on the current line
+func (p *dotenvParser) skipSpaces() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.advance()
+	}
+}
+
+// skipLine skips the rest of the current line
+func (p *dotenvParser) skipLine() {
+	for !p.done() && p.advance() != '\n' {
+	}
+}
+
+// entry reads one KEY=VALUE entry. The value is returned as written, without
+// quotes or escapes; quote is the quote character it was written in, if any.
+func (p *dotenvParser) entry() (key, value string, quote byte, err error) {
+	if strings.HasPrefix(p.src[p.i:], "export ") || strings.HasPrefix(p.src[p.i:], "export\t") {
+		p.i += len("export")
+		p.skipSpaces()
+	}
+
+	start := p.i
+	for !p.done() && isEnvKeyChar(p.peek()) {
+		p.advance()
+	}
+	key = p.src[start:p.i]
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		p.i = start
+		return "", "", 0, p.fail("invalid variable name")
+	}
+
+	p.skipSpaces()
+	if p.done() || p.peek() != '=' {
+		return "", "", 0, p.fail("expected \"=\" after %s", key)
+	}
+	p.advance()
+	spaced := !p.done() && (p.peek() == ' ' || p.peek() == '\t')
+	p.skipSpaces()
+
+	if !p.done() && (p.peek() == '\'' || p.peek() == '"') {
+		quote = p.peek()
+		if value, err = p.quoted(quote); err != nil {
+			return "", "", 0, err
+		}
+		// Only a comment may follow the closing quote
+		p.skipSpaces()
+		if !p.done() && p.peek() != '\n' && p.peek() != '\r' && p.peek() != '#' {
+			return "", "", 0, p.fail("unexpected %q after quoted value of %s", p.peek(), key)
+		}
+		p.skipLine()
+		return key, value, quote, nil
+	}
+
+	start = p.i
+	for !p.done() && p.peek() != '\n' {
+		// A # starts a comment when it follows whitespace
+		if p.peek() == '#' && (p.i == start && spaced || p.i > start && (p.src[p.i-1] == ' ' || p.src[p.i-1] == '\t')) {
+			break
+		}
+		p.advance()
+	}
+	value = strings.TrimSpace(p.src[start:p.i])
+	p.skipLine()
+	return key, value, 0, nil
+}
+
+// quoted reads a value in single or double quotes, which may span lines. In
+// double quotes \n, \r, \t, \" and \\ are unescaped; \$ is kept for the
+// variable expansion to turn into a literal dollar sign.
+func (p *dotenvParser) quoted(quote byte) (string, error) {
+	open := p.pos()
+	p.advance()
+
+	var b strings.Builder
+	for !p.done() {
+		c := p.advance()
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && quote == '"' && !p.done():
+			escaped := p.advance()
+			switch escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(escaped)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(escaped)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p.errPos = open
+	return "", errors.New("unterminated quoted value")
+}
+
+// isEnvKeyChar reports whether c can be part of a variable name
+func isEnvKeyChar(c byte) bool {
+	return isVarChar(c) || c == '.' || c == '-'
+}
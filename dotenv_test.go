@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("SELI_TEST_SYSTEM", "system")
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{"plain", "A=1\nB = two words \n", map[string]string{"A": "1", "B": "two words"}},
+		{"comments and blank lines", "# comment\n\n  # indented\nA=1\n", map[string]string{"A": "1"}},
+		{"export prefix", "export A=1\nexport\tB=2\nexport=3\n", map[string]string{"A": "1", "B": "2", "export": "3"}},
+		{"inline comment", "A=value # comment\nB= # comment\nC=#not-a-comment\nD=a#b\n", map[string]string{"A": "value", "B": "", "C": "#not-a-comment", "D": "a#b"}},
+		{"empty", "A=\nB=''\nC=\"\"\n", map[string]string{"A": "", "B": "", "C": ""}},
+		{"double quotes", `A="a # b" # comment` + "\n" + `B="say \"hi\""`, map[string]string{"A": "a # b", "B": `say "hi"`}},
+		{"escapes", `A="line1\nline2\ttab\\"` + "\nB='no\\nescape'\nC=no\\nescape\n", map[string]string{"A": "line1\nline2\ttab\\", "B": `no\nescape`, "C": `no\nescape`}},
+		{"multi-line", "A=\"first\nsecond\"\nB='one\ntwo'\nC=3\n", map[string]string{"A": "first\nsecond", "B": "one\ntwo", "C": "3"}},
+		{"crlf", "A=1\r\nB=\"2\"\r\n", map[string]string{"A": "1", "B": "2"}},
+		{"interpolation", "HOST=example.com\nURL=https://${HOST}:${PORT:-443}\nBARE=$HOST/x\n", map[string]string{"HOST": "example.com", "URL": "https://example.com:443", "BARE": "example.com/x"}},
+		{"interpolation in double quotes", "A=1\nB=\"${A} and $A\"\nC='${A}'\n", map[string]string{"A": "1", "B": "1 and 1", "C": "${A}"}},
+		{"escaped dollar", `A=1` + "\n" + `B="\${A}"` + "\n" + `C=\$A`, map[string]string{"A": "1", "B": "${A}", "C": "$A"}},
+		{"system and outer variables", "A=${SELI_TEST_SYSTEM}-${OUTER}\n", map[string]string{"A": "system-outer"}},
+		{"later definitions override", "A=1\nA=${A}2\n", map[string]string{"A": "12"}},
+		{"dotted and dashed names", "app.port=1\nMY-VAR=2\n", map[string]string{"app.port": "1", "MY-VAR": "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := parseDotenv(tt.input, map[string]string{"OUTER": "outer"})
+			if err != nil {
+				t.Fatalf("parseDotenv() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   string
+		err   string
+	}{
+		{"missing equals", "A=1\nJUSTAKEY\n", "2:9", `expected "=" after JUSTAKEY`},
+		{"invalid name", "A=1\n\n1A=2\n", "3:1", "invalid variable name"},
+		{"invalid character", "A=1\n=2\n", "2:1", "invalid variable name"},
+		{"unterminated double quote", "A=1\nB=\"open\nC=2\n", "2:3", "unterminated quoted value"},
+		{"unterminated single quote", "A='open", "1:3", "unterminated quoted value"},
+		{"text after quote", "A=\"x\" y\n", "1:7", `unexpected 'y' after quoted value of A`},
+		{"required variable", "A=1\nB=${SELI_TEST_MISSING:?set it in the shell}\n", "2:1", "B: SELI_TEST_MISSING: set it in the shell"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, pos, err := parseDotenv(tt.input, nil)
+			if err == nil {
+				t.Fatal("parseDotenv() error = nil")
+			}
+			if pos.String() != tt.pos || err.Error() != tt.err {
+				t.Errorf("parseDotenv() error = %s: %v, want %s: %s", pos, err, tt.pos, tt.err)
+			}
+		})
+	}
+}
+
+func TestEnvFileErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	envPath := writeTestFile(t, configDir, ".env", "TOKEN=\"unterminated\n")
+	path := writeTestFile(t, configDir, "app.yml", "name: App\ncommands:\n  - name: Run\n    command: echo $TOKEN\n")
+
+	_, err := LoadConfigFile(path, "")
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || configErr.Path != envPath || configErr.Pos.Line != 1 {
+		t.Fatalf("LoadConfigFile() error = %v, want an error on line 1 of the .env file", err)
+	}
+
+	problems := validateConfigFile(path, "")
+	if len(problems) != 1 || !strings.HasSuffix(problems[0].String(), ".env:1:7: unterminated quoted value") {
+		t.Errorf("validateConfigFile() = %v, want the .env position", problems)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// showError opens the error panel for err
+func (m Model) showError(err error) Model {
+	m.err = err
+	return m
+}
+
+// updateError handles keys while the error panel is shown. Any key but
+// Ctrl+C dismisses it.
+func (m Model) updateError(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	m.err = nil
+	return m, nil
+}
+
+// errorView renders the error panel. Errors in config files show the file
+// position and the offending line with a caret under the column.
+func (m Model) errorView() string {
+	var b strings.Builder
+	b.WriteString(errorStyle.Render("Error") + "\n\n")
+
+	var configErr *ConfigError
+	if errors.As(m.err, &configErr) {
+		location := configErr.Path
+		if configErr.Pos.Line > 0 {
+			location = fmt.Sprintf("%s:%s", location, configErr.Pos)
+		}
+		b.WriteString(location + "\n")
+		b.WriteString(configErr.Err.Error() + "\n")
+		if configErr.Source != "" {
+			gutter := fmt.Sprintf("%d | ", configErr.Pos.Line)
+			b.WriteString("\n" + lipgloss.NewStyle().Faint(true).Render(gutter) + strings.ReplaceAll(configErr.Source, "\t", " ") + "\n")
+			if configErr.Pos.Column > 0 {
+				b.WriteString(strings.Repeat(" ", len(gutter)+configErr.Pos.Column-1) + errorStyle.Render("^") + "\n")
+			}
+		}
+	} else {
+		b.WriteString(m.err.Error() + "\n")
+	}
+	b.WriteString("\n" + lipgloss.NewStyle().Faint(true).Render("press any key to continue"))
+
+	panel := errorPanelStyle
+	if m.width > 0 {
+		panel = panel.MaxWidth(m.width)
+	}
+	box := panel.Render(b.String())
+	if m.width == 0 || m.height == 0 {
+		return box
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// forwardedSignals are relayed to a foreground child while it runs
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
 // CommandExecutor handles command execution with environment variables
 type CommandExecutor struct{}
 
@@ -25,59 +35,46 @@ func (e *CommandExecutor) ExecuteCommand(config CommandConfig, fileShow *bool) e
 		shouldShow = *fileShow
 	}
 
-	// Prepare the command and arguments
-	var cmd *exec.Cmd
-	var displayArgs []string
+	// Secrets are resolved only now, right before the launch
+	config, secrets, err := resolveSecrets(config)
+	if err != nil {
+		return err
+	}
 
-	if len(config.Args) > 0 {
-		// Command with arguments
-		displayArgs = append([]string{config.Command}, config.Args...)
-		cmd = exec.Command(displayArgs[0], displayArgs[1:]...)
-	} else {
-		// Simple command (may contain spaces, need to split)
-		parts := strings.Fields(config.Command)
-		if len(parts) == 0 {
-			return fmt.Errorf("empty command")
-		}
-		displayArgs = parts
-		cmd = exec.Command(parts[0], parts[1:]...)
+	// Prepare the command and arguments
+	cmd, argv, err := buildCommand(config)
+	if err != nil {
+		return err
 	}
 
-	// Show command details if requested
+	// Show command details if requested, with the secrets masked
 	if shouldShow {
+		displayArgs := maskArgv(argv, secrets)
 		fmt.Printf("\nExecuting command: %s\n", config.Name)
-		fmt.Printf("Executing: %s", displayArgs[0])
-		for i := 1; i < len(displayArgs); i++ {
-			fmt.Printf(" %q", displayArgs[i])
-		}
-		fmt.Println()
+		fmt.Printf("Executing: %s\n", JoinCommandLine(displayArgs))
+		fmt.Printf("Argv: %q\n", displayArgs)
 
 		if len(config.Env) > 0 {
 			fmt.Println("Environment variables:")
 			for k, v := range config.Env {
-				fmt.Printf("  %s=%q\n", k, v)
+				fmt.Printf("  %s=%q\n", k, maskSecrets(v, secrets))
 			}
 		}
 
-		if config.WorkDir != "" {
-			fmt.Printf("Working directory: %q\n", config.WorkDir)
+		if len(config.dotenv) > 0 {
+			names := make([]string, 0, len(config.dotenv))
+			for k := range config.dotenv {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			fmt.Printf("Exported from .env: %s\n", strings.Join(names, ", "))
 		}
 
-		fmt.Println()
-	}
-
-	// Set working directory if specified
-	if config.WorkDir != "" {
-		cmd.Dir = config.WorkDir
-	}
-
-	// Set environment variables
-	if len(config.Env) > 0 {
-		env := os.Environ()
-		for key, value := range config.Env {
-			env = append(env, fmt.Sprintf("%s=%s", key, value))
+		if dir := commandDir(config); dir != "" {
+			fmt.Printf("Working directory: %q\n", maskSecrets(dir, secrets))
 		}
-		cmd.Env = env
+
+		fmt.Println()
 	}
 
 	// Set standard input/output to current terminal
@@ -86,37 +83,183 @@ func (e *CommandExecutor) ExecuteCommand(config CommandConfig, fileShow *bool) e
 	cmd.Stderr = os.Stderr
 
 	// Execute the command
-	return cmd.Run()
+	return runForeground(cmd, config.timeout())
 }
 
-// ExecuteCommandInBackground executes a command in background (for future use)
-func (e *CommandExecutor) ExecuteCommandInBackground(config CommandConfig) (*exec.Cmd, error) {
-	var cmd *exec.Cmd
+// commandDir returns the directory the command runs in. A relative workDir of
+// a project command is resolved against the project root.
+func commandDir(config CommandConfig) string {
+	if config.WorkDir == "" || filepath.IsAbs(config.WorkDir) || config.projectRoot == "" {
+		return config.WorkDir
+	}
+	return filepath.Join(config.projectRoot, config.WorkDir)
+}
 
-	if len(config.Args) > 0 {
-		args := append([]string{config.Command}, config.Args...)
-		cmd = exec.Command(args[0], args[1:]...)
-	} else {
-		parts := strings.Fields(config.Command)
-		if len(parts) == 0 {
-			return nil, fmt.Errorf("empty command")
+// commandEnviron builds the environment of the child process. Later layers
+// override earlier ones: seli's own environment, then the exported .env
+// variables, then the command's env. It returns nil, meaning "inherit seli's
+// environment", when the command adds nothing.
+func commandEnviron(config CommandConfig) []string {
+	if len(config.dotenv) == 0 && len(config.Env) == 0 {
+		return nil
+	}
+
+	env := os.Environ()
+	for key, value := range config.dotenv {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	for key, value := range config.Env {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	return env
+}
+
+// runForeground starts cmd and waits for it to finish. SIGINT, SIGTERM and
+// SIGHUP received by seli in the meantime are forwarded to the child instead
+// of terminating seli, so the child decides how to exit. A child still
+// running after timeout, unless it is 0, receives SIGTERM and is killed if it
+// does not exit within stopTimeout.
+func runForeground(cmd *exec.Cmd, timeout time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan bool, 1)
+	go func() {
+		var expired, kill <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		timedOut := false
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-expired:
+				timedOut = true
+				_ = cmd.Process.Signal(syscall.SIGTERM)
+				kill = time.After(stopTimeout)
+			case <-kill:
+				_ = cmd.Process.Kill()
+			case <-done:
+				stopped <- timedOut
+				return
+			}
 		}
-		cmd = exec.Command(parts[0], parts[1:]...)
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	if <-stopped {
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
 	}
+	return err
+}
 
-	if config.WorkDir != "" {
-		cmd.Dir = config.WorkDir
+// ExitCode maps an error returned by ExecuteCommand to a process exit code.
+// The boolean reports whether the code is the child's own status: a normal
+// exit yields the child's status and death by a signal yields 128+signal. For
+// launch failures it returns false with 127 (not found), 126 (not executable)
+// or 1, following shell conventions.
+func ExitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
 	}
 
-	if len(config.Env) > 0 {
-		env := os.Environ()
-		for key, value := range config.Env {
-			env = append(env, fmt.Sprintf("%s=%s", key, value))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), true
 		}
-		cmd.Env = env
+		return exitErr.ExitCode(), true
+	}
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return 127, false
+	case errors.Is(err, fs.ErrPermission):
+		return 126, false
+	}
+	return 1, false
+}
+
+// ExecuteCommandInBackground starts a command without waiting for it. The
+// caller resolves the secrets of config first, and is responsible for calling
+// Wait on the returned command.
+func (e *CommandExecutor) ExecuteCommandInBackground(config CommandConfig, opts ...BackgroundOption) (*exec.Cmd, error) {
+	cmd, _, err := buildCommand(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(cmd)
 	}
 
 	// Start the command in background
-	err := cmd.Start()
+	err = cmd.Start()
 	return cmd, err
 }
+
+// commandArgv returns the argv a command runs with. In shell mode the script
+// (or the command line made of command and args) is handed to the shell.
+func commandArgv(config CommandConfig) ([]string, error) {
+	if config.Script != "" {
+		// A script always needs a shell; `shell: false` falls back to the default one
+		shell := config.Shell
+		if !shell.Enabled() {
+			shell = shellDefault
+		}
+		return shellArgv(shell, config.Script, config.Args), nil
+	}
+
+	if config.Shell.Enabled() {
+		line := strings.TrimSpace(strings.Join(append([]string{config.Command}, config.Args...), " "))
+		if line == "" {
+			return nil, fmt.Errorf("empty command")
+		}
+		return shellArgv(config.Shell, line, nil), nil
+	}
+
+	if len(config.Args) > 0 {
+		// Command with arguments
+		return append([]string{config.Command}, config.Args...), nil
+	}
+
+	// Simple command (may contain spaces and quotes, need to split)
+	parts, err := SplitCommandLine(config.Command)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return parts, nil
+}
+
+// buildCommand prepares an exec.Cmd with the argv, working directory and
+// environment of the given configuration
+func buildCommand(config CommandConfig) (*exec.Cmd, []string, error) {
+	argv, err := commandArgv(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+
+	// Set working directory if specified
+	if dir := commandDir(config); dir != "" {
+		cmd.Dir = dir
+	}
+
+	// Set environment variables
+	cmd.Env = commandEnviron(config)
+
+	return cmd, argv, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -103,3 +104,131 @@ func TestCommandExecutor_WithEmptyCommand(t *testing.T) {
 		t.Error("expected error for empty command")
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	executor := NewCommandExecutor()
+
+	tests := []struct {
+		name         string
+		command      CommandConfig
+		expectedCode int
+		fromChild    bool
+	}{
+		{
+			name:         "Successful command",
+			command:      CommandConfig{Name: "True", Command: "true"},
+			expectedCode: 0,
+			fromChild:    true,
+		},
+		{
+			name:         "Child exit status",
+			command:      CommandConfig{Name: "Exit 3", Command: "sh", Args: []string{"-c", "exit 3"}},
+			expectedCode: 3,
+			fromChild:    true,
+		},
+		{
+			name:         "Quoted command line",
+			command:      CommandConfig{Name: "Quoted", Command: `sh -c "exit 7"`},
+			expectedCode: 7,
+			fromChild:    true,
+		},
+		{
+			name:         "Child killed by signal",
+			command:      CommandConfig{Name: "Kill", Command: "sh", Args: []string{"-c", "kill -TERM $$"}},
+			expectedCode: 128 + 15,
+			fromChild:    true,
+		},
+		{
+			name:         "Executable not found",
+			command:      CommandConfig{Name: "Missing", Command: "nonexistentcommand12345"},
+			expectedCode: 127,
+			fromChild:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := executor.ExecuteCommand(tt.command, nil)
+			code, fromChild := ExitCode(err)
+			if code != tt.expectedCode || fromChild != tt.fromChild {
+				t.Errorf("ExitCode(%v) = (%d, %v), want (%d, %v)", err, code, fromChild, tt.expectedCode, tt.fromChild)
+			}
+		})
+	}
+}
+
+func TestCommandArgvShellMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  CommandConfig
+		expected []string
+	}{
+		{
+			name:     "Command line through the default shell",
+			command:  CommandConfig{Command: "docker", Args: []string{"stop", "$(docker ps -q)"}, Shell: shellDefault},
+			expected: []string{"sh", "-c", "docker stop $(docker ps -q)"},
+		},
+		{
+			name:     "Named shell",
+			command:  CommandConfig{Command: "ls *.go | wc -l", Shell: "bash"},
+			expected: []string{"bash", "-c", "ls *.go | wc -l"},
+		},
+		{
+			name:     "Script with positional parameters",
+			command:  CommandConfig{Script: "echo \"$1\"\necho \"$2\"", Args: []string{"a b", "c"}, Shell: "zsh"},
+			expected: []string{"zsh", "-c", "echo \"$1\"\necho \"$2\"", "zsh", "a b", "c"},
+		},
+		{
+			name:     "Script implies a shell",
+			command:  CommandConfig{Script: "exit 0", Shell: shellOff},
+			expected: []string{"sh", "-c", "exit 0"},
+		},
+		{
+			name:     "Shell disabled",
+			command:  CommandConfig{Command: "echo hello", Shell: shellOff},
+			expected: []string{"echo", "hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argv, err := commandArgv(tt.command)
+			if err != nil {
+				t.Fatalf("commandArgv() error = %v", err)
+			}
+			if strings.Join(argv, "\x00") != strings.Join(tt.expected, "\x00") {
+				t.Errorf("commandArgv() = %q, want %q", argv, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandInShell(t *testing.T) {
+	executor := NewCommandExecutor()
+
+	tests := []struct {
+		name         string
+		command      CommandConfig
+		expectedCode int
+	}{
+		{
+			name:         "Pipe and subshell",
+			command:      CommandConfig{Name: "Pipe", Command: "test \"$(echo hello | tr a-z A-Z)\" = HELLO", Shell: shellDefault},
+			expectedCode: 0,
+		},
+		{
+			name:         "Multi-line script with arguments",
+			command:      CommandConfig{Name: "Script", Script: "test \"$1\" = \"a b\" || exit 4\nexit 5", Args: []string{"a b"}},
+			expectedCode: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := ExitCode(executor.ExecuteCommand(tt.command, nil))
+			if code != tt.expectedCode {
+				t.Errorf("exit code = %d, want %d", code, tt.expectedCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// varError is a required variable, written as ${VAR:?message}, that is not set
+type varError struct {
+	// Command is the name of the command whose field references the variable
+	Command string
+	// Field is the path of the field within the command, e.g. "args.0"
+	Field   string
+	Name    string
+	Message string
+
+	// path is the path of the field within the config file
+	path string
+}
+
+// Error formats the error like a shell does, naming the command and field
+func (e *varError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = "parameter not set"
+	}
+	return fmt.Sprintf("command %q: %s: %s: %s", e.Command, e.Field, e.Name, message)
+}
+
+// ExpandEnvVars expands environment variables in a string with escape support.
+// Variables that envVars does not define are looked up in the system
+// environment.
+func ExpandEnvVars(input string, envVars map[string]string) string {
+	x := &expander{vars: envVars}
+	return x.expand(input)
+}
+
+// expandVars expands the variable references in input using vars. It also
+// returns the names of ${VAR} references without a default that vars does
+// not define, and an error for the first ${VAR:?message} whose variable is
+// unset or empty.
+//
+// Supported forms are ${VAR}, $VAR, ${VAR:-default}, ${VAR:?message} and
+// ${VAR:+alternative}; the words after the operators are expanded in turn.
+// \$ writes a literal dollar sign. ${param.NAME} is left for the parameter
+// substitution and secret references such as ${file:PATH} for
+// resolveSecrets; a bare $VAR that is not defined is kept as written so that
+// shell variables in scripts keep working.
+func expandVars(input string, vars map[string]string) (string, []string, *varError) {
+	x := &expander{vars: vars}
+	expanded := x.expand(input)
+	return expanded, x.undefined, x.err
+}
+
+// expandShellVars is expandVars for text run by a shell, such as a script.
+// Only ${VAR} references are expanded, and not inside single quotes; bare
+// $VAR references and backslash escapes are left for the shell, which knows
+// the values at that point of the script, e.g. $PWD after a cd.
+func expandShellVars(input string, vars map[string]string) (string, []string, *varError) {
+	x := &expander{vars: vars, shell: true}
+	expanded := x.expand(input)
+	return expanded, x.undefined, x.err
+}
+
+// expander expands one string, collecting undefined and missing variables
+type expander struct {
+	vars      map[string]string
+	undefined []string
+	err       *varError
+	// shell is set for shell text, see expandShellVars
+	shell bool
+}
+
+// lookup returns the value of a variable, falling back to the system environment
+func (x *expander) lookup(name string) (string, bool) {
+	if value, ok := x.vars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// expand expands every reference in s
+func (x *expander) expand(s string) string {
+	var b strings.Builder
+	inDouble := false
+	for i := 0; i < len(s); {
+		switch {
+		case x.shell && s[i] == '\\' && i+1 < len(s):
+			b.WriteString(s[i : i+2])
+			i += 2
+		case x.shell && s[i] == '"':
+			inDouble = !inDouble
+			b.WriteByte(s[i])
+			i++
+		case x.shell && s[i] == '\'' && !inDouble:
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(s[i : i+end+2])
+			i += end + 2
+		case strings.HasPrefix(s[i:], `\$`):
+			b.WriteByte('$')
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				// An unterminated reference is kept as written
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(x.braced(s[i:end+1], s[i+2:end]))
+			i = end + 1
+		case s[i] == '$' && i+1 < len(s) && isVarStart(s[i+1]) && !x.shell:
+			j := i + 2
+			for j < len(s) && isVarChar(s[j]) {
+				j++
+			}
+			if value, ok := x.lookup(s[i+1 : j]); ok {
+				b.WriteString(value)
+			} else {
+				b.WriteString(s[i:j])
+			}
+			i = j
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// braced expands the reference ref, whose text between the braces is inner
+func (x *expander) braced(ref, inner string) string {
+	// Parameters and secrets are substituted right before execution
+	if strings.HasPrefix(inner, "param.") {
+		return ref
+	}
+	if _, _, ok := secretRef(inner); ok {
+		return ref
+	}
+
+	name, op, word := inner, "", ""
+	for _, candidate := range []string{":-", ":?", ":+"} {
+		if i := strings.Index(inner, candidate); i > 0 && (op == "" || i < len(name)) {
+			name, op, word = inner[:i], candidate, inner[i+2:]
+		}
+	}
+
+	value, ok := x.lookup(name)
+	set := ok && value != ""
+	switch op {
+	case ":-":
+		if set {
+			return value
+		}
+		return x.expand(word)
+	case ":?":
+		if set {
+			return value
+		}
+		if x.err == nil {
+			x.err = &varError{Name: name, Message: x.expand(word)}
+		}
+		return ""
+	case ":+":
+		if set {
+			return x.expand(word)
+		}
+		return ""
+	}
+
+	if !ok {
+		x.undefined = append(x.undefined, name)
+	}
+	return value
+}
+
+// closingBrace returns the index of the brace that closes a reference whose
+// contents start at start, skipping nested references, or -1
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], `\$`):
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isVarStart reports whether c can start a bare variable name
+func isVarStart(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// isVarChar reports whether c can continue a bare variable name
+func isVarChar(c byte) bool {
+	return isVarStart(c) || c >= '0' && c <= '9'
+}
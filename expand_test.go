@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("SELI_TEST_SYSTEM", "system")
+	vars := map[string]string{
+		"HOST":  "example.com",
+		"PORT":  "8080",
+		"EMPTY": "",
+		"NAME":  "HOST",
+	}
+
+	tests := []struct {
+		input     string
+		want      string
+		undefined []string
+		missing   string
+	}{
+		{"${HOST}:${PORT}", "example.com:8080", nil, ""},
+		{"$HOST:$PORT/path", "example.com:8080/path", nil, ""},
+		{"${SELI_TEST_SYSTEM} $SELI_TEST_SYSTEM", "system system", nil, ""},
+		{"${MISSING}", "", []string{"MISSING"}, ""},
+		{"for f in *; do echo $f $1; done", "for f in *; do echo $f $1; done", nil, ""},
+		{`\${HOST} \$HOST costs \$5`, "${HOST} $HOST costs $5", nil, ""},
+		{"${MISSING:-localhost}", "localhost", nil, ""},
+		{"${EMPTY:-fallback}", "fallback", nil, ""},
+		{"${HOST:-fallback}", "example.com", nil, ""},
+		{"${MISSING:-${HOST}:${PORT}}", "example.com:8080", nil, ""},
+		{"${MISSING:-${OTHER:-deep}}", "deep", nil, ""},
+		{"${PORT:+--port=$PORT}", "--port=8080", nil, ""},
+		{"${MISSING:+--port=$PORT}", "", nil, ""},
+		{"${EMPTY:+set}", "", nil, ""},
+		{"${HOST:?HOST is required}", "example.com", nil, ""},
+		{"x${TOKEN:?set TOKEN in .env}x", "xx", nil, "TOKEN: set TOKEN in .env"},
+		{"${EMPTY:?}", "", nil, "EMPTY: "},
+		{"${MISSING:-${TOKEN:?no token}}", "", nil, "TOKEN: no token"},
+		{"${HOST:-${TOKEN:?no token}}", "example.com", nil, ""},
+		{"${param.branch} ${param.x:-y}", "${param.branch} ${param.x:-y}", nil, ""},
+		{"${HOST", "${HOST", nil, ""},
+		{"$", "$", nil, ""},
+	}
+
+	for _, tt := range tests {
+		got, undefined, err := expandVars(tt.input, vars)
+		if got != tt.want {
+			t.Errorf("expandVars(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if strings.Join(undefined, ",") != strings.Join(tt.undefined, ",") {
+			t.Errorf("expandVars(%q) undefined = %v, want %v", tt.input, undefined, tt.undefined)
+		}
+		missing := ""
+		if err != nil {
+			missing = err.Name + ": " + err.Message
+		}
+		if missing != tt.missing {
+			t.Errorf("expandVars(%q) error = %q, want %q", tt.input, missing, tt.missing)
+		}
+	}
+}
+
+func TestRequiredVariable(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	path := writeTestFile(t, configDir, "deploy.yml", `name: Deploy
+commands:
+  - name: Status
+    command: echo ${DEPLOY_HOST:-localhost}
+  - name: Release
+    steps:
+      - name: Upload
+        command: upload
+        args: ["--token", "${SELI_TEST_TOKEN:?set SELI_TEST_TOKEN in .env}"]
+`)
+
+	_, err := LoadConfigFile(path, "")
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("LoadConfigFile() error = %v, want a *ConfigError", err)
+	}
+	want := `command "Release": steps.0.args.1: SELI_TEST_TOKEN: set SELI_TEST_TOKEN in .env`
+	if configErr.Err.Error() != want || configErr.Pos.Line != 9 {
+		t.Errorf("LoadConfigFile() error = %v, want %q on line 9", err, want)
+	}
+
+	t.Setenv("SELI_TEST_TOKEN", "secret")
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v with the variable set", err)
+	}
+	if got := config.Commands[0].Command; got != "echo localhost" {
+		t.Errorf("Command = %q, want the default", got)
+	}
+	if got := config.Commands[1].Steps[0].Args[1]; got != "secret" {
+		t.Errorf("Args[1] = %q, want the variable", got)
+	}
+}
+
+func TestExpandShellVars(t *testing.T) {
+	vars := map[string]string{"HOST": "example.com", "TOKEN": "s3cr3t", "PWD": "/seli/start"}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"cd /tmp && echo $PWD", "cd /tmp && echo $PWD"},
+		{"curl ${HOST} -H \"Host: ${HOST}\"", "curl example.com -H \"Host: example.com\""},
+		{"echo '$TOKEN ${TOKEN}' \"${TOKEN}\"", "echo '$TOKEN ${TOKEN}' \"s3cr3t\""},
+		{`echo "it's ${HOST}" \'${HOST}`, `echo "it's example.com" \'example.com`},
+		{`echo \${HOST} \$HOST`, `echo \${HOST} \$HOST`},
+		{"echo ${MISSING:-$HOST}", "echo $HOST"},
+	}
+
+	for _, tt := range tests {
+		if got, _, _ := expandShellVars(tt.input, vars); got != tt.want {
+			t.Errorf("expandShellVars(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestShellVariablesAreLeftToTheShell(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SELI_TEST_TOKEN", "s3cr3t")
+	dir := t.TempDir()
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "shell.yml", `name: Shell
+commands:
+  - name: Command
+    command: cd `+dir+` && echo $PWD '$SELI_TEST_TOKEN'
+    shell: true
+  - name: Script
+    script: |
+      cd `+dir+`
+      echo "now in $PWD"
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	for i, want := range []string{dir + " $SELI_TEST_TOKEN\n", "now in " + dir + "\n"} {
+		argv, err := commandArgv(config.Commands[i])
+		if err != nil {
+			t.Fatalf("commandArgv() error = %v", err)
+		}
+		out, err := exec.Command(argv[0], argv[1:]...).Output()
+		if err != nil || string(out) != want {
+			t.Errorf("%s printed %q, %v, want %q", config.Commands[i].Name, out, err, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#A6A3FF"))
+
+	focusedLabelStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#EE6FF8")).
+				Bold(true)
+
+	helpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262"))
+)
+
+// paramField is one input of the parameter form. Choice and bool parameters
+// cycle through choices; all other types use a text input.
+type paramField struct {
+	param   ParamConfig
+	input   textinput.Model
+	choices []string
+	choice  int
+	err     string
+}
+
+// paramForm collects the parameters of a command before it is executed
+type paramForm struct {
+	command CommandConfig
+	fields  []paramField
+	focus   int
+}
+
+// newParamForm creates the form for the parameters of cmd, loading the
+// choices of choice parameters
+func newParamForm(cmd CommandConfig) paramForm {
+	form := paramForm{command: cmd}
+
+	for _, p := range cmd.Params {
+		field := paramField{param: p}
+
+		switch p.Kind() {
+		case paramBool:
+			field.choices = []string{"true", "false"}
+			if value, err := strconv.ParseBool(p.Default); err == nil && !value {
+				field.choice = 1
+			} else if p.Default == "" {
+				field.choice = 1
+			}
+
+		case paramChoice:
+			choices, err := p.LoadChoices(cmd)
+			if err != nil {
+				field.err = err.Error()
+			}
+			field.choices = choices
+			for i, choice := range choices {
+				if choice == p.Default {
+					field.choice = i
+				}
+			}
+
+		default:
+			input := textinput.New()
+			input.Prompt = ""
+			input.SetValue(p.Default)
+			input.CursorEnd()
+			if p.Kind() == paramPassword {
+				input.EchoMode = textinput.EchoPassword
+			}
+			field.input = input
+		}
+
+		form.fields = append(form.fields, field)
+	}
+
+	form.focusField(0)
+	return form
+}
+
+// isTextField reports whether the field uses a text input
+func (f paramField) isTextField() bool {
+	return f.choices == nil && f.param.Kind() != paramChoice
+}
+
+// value returns the current value of the field
+func (f paramField) value() string {
+	if f.isTextField() {
+		return f.input.Value()
+	}
+	if f.choice < len(f.choices) {
+		return f.choices[f.choice]
+	}
+	return ""
+}
+
+// focusField moves the focus to the field at index i
+func (f *paramForm) focusField(i int) {
+	if len(f.fields) == 0 {
+		return
+	}
+	if f.focus < len(f.fields) && f.fields[f.focus].isTextField() {
+		f.fields[f.focus].input.Blur()
+	}
+	f.focus = (i + len(f.fields)) % len(f.fields)
+	if f.fields[f.focus].isTextField() {
+		f.fields[f.focus].input.Focus()
+	}
+}
+
+// values returns the entered values by parameter name
+func (f paramForm) values() map[string]string {
+	values := make(map[string]string)
+	for _, field := range f.fields {
+		values[field.param.Name] = field.value()
+	}
+	return values
+}
+
+// validate checks every field, records the errors and focuses the first
+// invalid field. It reports whether all values are valid.
+func (f *paramForm) validate() bool {
+	first := -1
+	for i := range f.fields {
+		field := &f.fields[i]
+		field.err = ""
+		if err := field.param.Check(field.value(), field.choices); err != nil {
+			field.err = err.Error()
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first >= 0 {
+		f.focusField(first)
+		return false
+	}
+	return true
+}
+
+// Update handles a message. The returned bool reports whether the form was
+// submitted with valid values.
+func (f paramForm) Update(msg tea.Msg) (paramForm, tea.Cmd, bool) {
+	if len(f.fields) == 0 {
+		return f, nil, true
+	}
+	field := &f.fields[f.focus]
+
+	key, _ := msg.(tea.KeyMsg)
+	switch key.Type {
+	case tea.KeyTab, tea.KeyDown:
+		f.focusField(f.focus + 1)
+		return f, nil, false
+
+	case tea.KeyShiftTab, tea.KeyUp:
+		f.focusField(f.focus - 1)
+		return f, nil, false
+
+	case tea.KeyEnter:
+		if f.focus < len(f.fields)-1 {
+			f.focusField(f.focus + 1)
+			return f, nil, false
+		}
+		return f, nil, f.validate()
+
+	case tea.KeyLeft, tea.KeyRight, tea.KeySpace:
+		if !field.isTextField() {
+			if len(field.choices) > 0 {
+				step := 1
+				if key.Type == tea.KeyLeft {
+					step = -1
+				}
+				field.choice = (field.choice + step + len(field.choices)) % len(field.choices)
+			}
+			return f, nil, false
+		}
+	}
+
+	if !field.isTextField() {
+		return f, nil, false
+	}
+
+	var cmd tea.Cmd
+	field.input, cmd = field.input.Update(msg)
+	return f, cmd, false
+}
+
+// View renders the form
+func (f paramForm) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Parameters for %s", f.command.Name)))
+	b.WriteString("\n\n")
+
+	for i, field := range f.fields {
+		label := labelStyle
+		cursor := "  "
+		if i == f.focus {
+			label = focusedLabelStyle
+			cursor = "> "
+		}
+		b.WriteString(cursor + label.Render(field.param.Label()) + "\n")
+
+		if field.isTextField() {
+			b.WriteString("  " + field.input.View() + "\n")
+		} else if len(field.choices) > 0 {
+			b.WriteString(fmt.Sprintf("  ‹ %s ›\n", field.value()))
+		}
+
+		if field.err != "" {
+			b.WriteString("  " + errorStyle.Render(field.err) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(helpStyle.Render("tab/↓: next • shift+tab/↑: previous • ←/→: change choice • enter: run • esc: cancel"))
+	return b.String()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxHistoryEntries is the number of executions kept in the history file
+const maxHistoryEntries = 1000
+
+// HistoryEntry records one command execution
+type HistoryEntry struct {
+	Time       time.Time         `json:"time"`
+	ConfigPath string            `json:"configPath"`
+	Command    string            `json:"command"`
+	Argv       []string          `json:"argv"`
+	WorkDir    string            `json:"workDir,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Profile    string            `json:"profile,omitempty"`
+	ExitCode   int               `json:"exitCode"`
+	Duration   time.Duration     `json:"duration"`
+}
+
+// HistoryPath returns the path of the history file in ~/.seli/
+func HistoryPath() (string, error) {
+	configDir, err := ConfigDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, ".history.jsonl"), nil
+}
+
+// LoadHistory returns the recorded executions, oldest first. A missing
+// history file is not an error.
+func LoadHistory() ([]HistoryEntry, error) {
+	path, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		// Skip lines that cannot be decoded rather than losing the whole history
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// AppendHistory records an execution, trimming the history file to the most
+// recent maxHistoryEntries entries
+func AppendHistory(entry HistoryEntry) error {
+	path, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) < maxHistoryEntries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open history %s: %w", path, err)
+		}
+		defer file.Close()
+		_, err = file.Write(append(line, '\n'))
+		return err
+	}
+
+	entries = append(entries[len(entries)-maxHistoryEntries+1:], entry)
+	var b strings.Builder
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// executeAndRecord executes cmd and appends the execution to the history.
+// params are the parameter values the command was run with; values of
+// password parameters and secrets are never recorded. Failing to write the history only
+// prints a warning.
+func executeAndRecord(executor *CommandExecutor, config *ConfigFile, cmd CommandConfig, params map[string]string) error {
+	entry := HistoryEntry{
+		Time:       time.Now(),
+		ConfigPath: config.Path,
+		Command:    cmd.Name,
+		WorkDir:    commandDir(cmd),
+		Profile:    config.profile,
+	}
+	// Secret references are recorded unresolved, and secret variables and
+	// password values masked
+	if argv, err := commandArgv(cmd); err == nil {
+		entry.Argv = maskArgv(argv, secretValues(cmd))
+	}
+
+	for _, p := range cmd.Params {
+		if value, ok := params[p.Name]; ok && p.Kind() != paramPassword {
+			if entry.Params == nil {
+				entry.Params = make(map[string]string)
+			}
+			entry.Params[p.Name] = value
+		}
+	}
+
+	var err error
+	switch {
+	case cmd.isChain():
+		err = executor.ExecuteChain(config, cmd)
+	case len(cmd.Parallel) > 0:
+		err = executor.ExecuteParallel(config, cmd)
+	default:
+		err = executor.ExecuteCommand(cmd, config.Show)
+	}
+
+	entry.Duration = time.Since(entry.Time)
+	entry.ExitCode, _ = ExitCode(err)
+	if histErr := AppendHistory(entry); histErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", histErr)
+	}
+
+	return err
+}
+
+// loadHistoryCommand loads the config file and command of a history entry
+// with the profile it ran with, whatever the active profile is now, and
+// applies the recorded parameters. When the recorded parameters no longer
+// satisfy the command, the config and the command without parameters applied
+// are returned together with the error. roots are the config roots of this
+// invocation.
+func loadHistoryCommand(entry HistoryEntry, roots []ConfigRoot) (*ConfigFile, CommandConfig, map[string]string, error) {
+	previous := activeProfile
+	activeProfile = entry.Profile
+	defer func() { activeProfile = previous }()
+
+	config, err := LoadConfigFile(entry.ConfigPath, rootDirOf(roots, entry.ConfigPath))
+	if err == nil {
+		err = checkProfile(config)
+	}
+	if err != nil {
+		return nil, CommandConfig{}, nil, err
+	}
+
+	for _, cmd := range config.Commands {
+		if cmd.Name != entry.Command {
+			continue
+		}
+		values, err := ResolveParams(cmd, entry.Params)
+		if err != nil {
+			return config, cmd, nil, err
+		}
+		return config, ApplyParams(cmd, values), values, nil
+	}
+
+	return nil, CommandConfig{}, nil, fmt.Errorf("command %q no longer exists in %s", entry.Command, entry.ConfigPath)
+}
+
+// describeHistoryEntry returns a one-line summary of an execution
+func describeHistoryEntry(entry HistoryEntry, now time.Time) string {
+	description := fmt.Sprintf("%s · exit %d · %s · %s",
+		relativeTime(entry.Time, now),
+		entry.ExitCode,
+		entry.Duration.Round(time.Millisecond),
+		displayPath(entry.ConfigPath))
+	if entry.Profile != "" {
+		description += " · profile " + entry.Profile
+	}
+	return description
+}
+
+// relativeTime formats t relative to now, e.g. "5m ago"
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+	return t.Format("2006-01-02")
+}
+
+// displayPath shortens a path below the home directory to ~/...
+func displayPath(path string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(homeDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAppendAndLoadHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	entries, err := LoadHistory()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Expected empty history, got %d entries, error %v", len(entries), err)
+	}
+
+	for i, name := range []string{"First", "Second"} {
+		entry := HistoryEntry{Time: time.Now(), ConfigPath: "/tmp/x.yml", Command: name, Argv: []string{"echo", name}, ExitCode: i}
+		if err := AppendHistory(entry); err != nil {
+			t.Fatalf("AppendHistory() error = %v", err)
+		}
+	}
+
+	entries, err = LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Command != "First" || entries[1].ExitCode != 1 {
+		t.Errorf("Unexpected history: %+v", entries)
+	}
+}
+
+func TestAppendHistoryTrimsOldEntries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := HistoryPath()
+	if err != nil {
+		t.Fatalf("HistoryPath() error = %v", err)
+	}
+
+	var b strings.Builder
+	for i := 0; i < maxHistoryEntries; i++ {
+		line, _ := json.Marshal(HistoryEntry{Command: "old"})
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	writeTestFile(t, filepath.Dir(path), filepath.Base(path), b.String())
+
+	if err := AppendHistory(HistoryEntry{Command: "new"}); err != nil {
+		t.Fatalf("AppendHistory() error = %v", err)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if len(entries) != maxHistoryEntries || entries[len(entries)-1].Command != "new" {
+		t.Errorf("Expected %d entries ending with the new one, got %d", maxHistoryEntries, len(entries))
+	}
+}
+
+func TestExecuteAndRecord(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "ops.yml", `name: Ops
+commands:
+  - name: Fail
+    command: sh
+    args: ["-c", "exit ${param.code}", "--password", "${param.token}"]
+    params:
+      - name: code
+        type: int
+      - name: token
+        type: password
+        default: hunter2
+`)
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	values := map[string]string{"code": "3", "token": "hunter2"}
+	cmd := ApplyParams(config.Commands[0], values)
+	err = executeAndRecord(NewCommandExecutor(), config, cmd, values)
+	if code, _ := ExitCode(err); code != 3 {
+		t.Fatalf("Expected exit code 3, got %d (%v)", code, err)
+	}
+
+	entries, err := LoadHistory()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one history entry, got %d (%v)", len(entries), err)
+	}
+	entry := entries[0]
+	if entry.ConfigPath != config.Path || entry.Command != "Fail" || entry.ExitCode != 3 {
+		t.Errorf("Unexpected history entry: %+v", entry)
+	}
+	if strings.Join(entry.Argv, " ") != "sh -c exit 3 --password ****" {
+		t.Errorf("Expected resolved argv, got %q", entry.Argv)
+	}
+	if entry.Params["code"] != "3" {
+		t.Errorf("Expected parameter values to be recorded, got %v", entry.Params)
+	}
+	if _, ok := entry.Params["token"]; ok {
+		t.Error("Password parameters must not be recorded")
+	}
+
+	// Nor may their values appear in the recorded argv
+	path, err = HistoryPath()
+	if err != nil {
+		t.Fatalf("HistoryPath() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the history file: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), `"--password","****"`) {
+		t.Errorf("Expected the password to be masked in the history file, got %s", data)
+	}
+
+	// The recorded execution can be loaded again with the same parameters
+	_, rerun, _, err := loadHistoryCommand(entry, nil)
+	if err != nil {
+		t.Fatalf("loadHistoryCommand() error = %v", err)
+	}
+	if strings.Join(rerun.Args[:2], " ") != "-c exit 3" {
+		t.Errorf("Expected recorded parameters to be applied, got %q", rerun.Args)
+	}
+}
+
+func TestHistoryKeepsProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "api.yml", `name: API
+profiles:
+  dev:
+    env:
+      TARGET: dev.example.com
+  prod:
+    env:
+      TARGET: prod.example.com
+commands:
+  - name: Ping
+    command: true ${TARGET}
+`)
+
+	setProfile(t, "prod")
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if err := executeAndRecord(NewCommandExecutor(), config, config.Commands[0], nil); err != nil {
+		t.Fatalf("executeAndRecord() error = %v", err)
+	}
+	entries, _ := LoadHistory()
+	if len(entries) != 1 || entries[0].Profile != "prod" {
+		t.Fatalf("Expected the profile to be recorded, got %+v", entries)
+	}
+
+	// Replaying the run under another profile still targets prod
+	setProfile(t, "dev")
+	rerunConfig, rerun, _, err := loadHistoryCommand(entries[0], nil)
+	if err != nil {
+		t.Fatalf("loadHistoryCommand() error = %v", err)
+	}
+	if rerun.Command != "true prod.example.com" || rerunConfig.profile != "prod" {
+		t.Errorf("Expected the recorded profile to be used, got %q with profile %q", rerun.Command, rerunConfig.profile)
+	}
+	if activeProfile != "dev" {
+		t.Errorf("Expected the active profile to be left alone, got %q", activeProfile)
+	}
+	if description := describeHistoryEntry(entries[0], time.Now()); !strings.HasSuffix(description, " · profile prod") {
+		t.Errorf("Expected the profile in the description, got %q", description)
+	}
+}
+
+func TestHistoryScreenRerun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "dev.yml", "name: Dev\ncommands:\n  - name: Build\n    command: make\n  - name: Test\n    command: make test\n")
+	for _, name := range []string{"Build", "Test"} {
+		if err := AppendHistory(HistoryEntry{Time: time.Now(), ConfigPath: path, Command: name}); err != nil {
+			t.Fatalf("AppendHistory() error = %v", err)
+		}
+	}
+
+	model := Model{
+		state: stateBrowsing,
+		list:  list.New([]list.Item{Item{title: "dev.yml"}}, list.NewDefaultDelegate(), 0, 0),
+	}
+
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("H")})
+	model = updated.(Model)
+	if model.state != stateHistory || len(model.list.Items()) != 2 {
+		t.Fatalf("Expected history screen with 2 entries, got state %v with %d items", model.state, len(model.list.Items()))
+	}
+	if first := model.list.Items()[0].(Item); first.title != "Test" {
+		t.Errorf("Expected most recent execution first, got %q", first.title)
+	}
+
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = updated.(Model)
+	if model.state != stateExecutingCommand || model.pending == nil || model.pending.Command != "make test" {
+		t.Errorf("Expected 'Test' to be pending, got state %v pending %+v", model.state, model.pending)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// resolveIncludes loads the files that config includes, each with its own
+// includes, defaults, .env files, profiles and dangerous setting, and returns
+// their commands. Their templates are added to config. Paths are relative to
+// the including file. Commands and templates of config win over included
+// ones with the same name, and earlier includes win over later ones. chain
+// holds the absolute paths of the files including config, outermost first,
+// to detect cycles.
+func resolveIncludes(config *ConfigFile, path, ext string, data []byte, strict bool, chain []string) ([]CommandConfig, error) {
+	if len(config.Include) == 0 {
+		return nil, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	chain = append(chain, absPath)
+	homeDir, _ := os.UserHomeDir()
+
+	names := make(map[string]bool, len(config.Commands))
+	for _, cmd := range config.Commands {
+		names[cmd.Name] = true
+	}
+
+	var commands []CommandConfig
+	for i, include := range config.Include {
+		includePath := expandHome(include, homeDir)
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(absPath), includePath)
+		}
+		includeErr := func(err error) error {
+			pos, _ := configPositions(ext, data)
+			return newConfigError(path, data, pos.find(fmt.Sprintf("include.%d", i)), err)
+		}
+
+		for _, including := range chain {
+			if including == includePath {
+				return nil, includeErr(fmt.Errorf("include cycle: %s", includeChain(append(chain, includePath))))
+			}
+		}
+
+		included, err := loadConfig(includePath, config.rootDir, strict, chain)
+		if err != nil {
+			return nil, includeErr(fmt.Errorf("include %q: %w", include, err))
+		}
+
+		for _, cmd := range included.Commands {
+			if names[cmd.Name] {
+				continue
+			}
+			names[cmd.Name] = true
+			// Its steps refer to the commands of the file it is written in
+			if cmd.source == nil {
+				cmd.source = included
+			}
+			// The included file asks for confirmation of its own commands
+			if included.Dangerous && !cmd.Confirm.IsSet() {
+				cmd.Confirm = confirmDefault
+				cmd.setOrigin("confirm", "dangerous")
+			}
+			commands = append(commands, cmd)
+		}
+		for name, template := range included.Templates {
+			if _, ok := config.Templates[name]; !ok {
+				if config.Templates == nil {
+					config.Templates = make(map[string]CommandConfig)
+				}
+				config.Templates[name] = template
+			}
+		}
+	}
+	return commands, nil
+}
+
+// includeChain formats the paths of an include chain as "a.yml -> b.yml"
+func includeChain(paths []string) string {
+	display := make([]string, len(paths))
+	for i, path := range paths {
+		display[i] = displayPath(path)
+	}
+	return strings.Join(display, " -> ")
+}
+
+// extendCommand completes cmd and its inline steps with the settings of the
+// template they extend, which may extend another template in turn. chain
+// holds the names of the templates being extended, to detect cycles.
+func extendCommand(cmd *CommandConfig, templates map[string]CommandConfig, chain []string) error {
+	if cmd.Extends != "" {
+		for _, name := range chain {
+			if name == cmd.Extends {
+				return fmt.Errorf("template cycle: %s", strings.Join(append(chain, cmd.Extends), " -> "))
+			}
+		}
+		template, ok := templates[cmd.Extends]
+		if !ok {
+			return fmt.Errorf("extends unknown template %q%s", cmd.Extends, templateHint(templates))
+		}
+
+		base := template.clone()
+		if err := extendCommand(&base, templates, append(chain, cmd.Extends)); err != nil {
+			return err
+		}
+		mergeTemplate(cmd, base, "template "+cmd.Extends)
+	}
+
+	for _, steps := range [][]StepConfig{cmd.DependsOn, cmd.Steps, cmd.Parallel} {
+		for i := range steps {
+			if steps[i].Ref == "" {
+				if err := extendCommand(&steps[i].CommandConfig, templates, chain); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// templateHint lists the defined templates for an error message
+func templateHint(templates map[string]CommandConfig) string {
+	if len(templates) == 0 {
+		return " (no templates are defined)"
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" (available: %s)", strings.Join(names, ", "))
+}
+
+// mergeTemplate sets every field that cmd leaves empty to the value of base,
+// recording source as its origin unless base inherited it itself. Env is
+// merged key by key, the command's own values winning.
+func mergeTemplate(cmd *CommandConfig, base CommandConfig, source string) {
+	origin := func(field string) string {
+		if inherited, ok := base.origins[field]; ok {
+			return inherited
+		}
+		return source
+	}
+
+	dst := reflect.ValueOf(cmd).Elem()
+	src := reflect.ValueOf(base)
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if !field.IsExported() || src.Field(i).IsZero() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Type.Kind() == reflect.Map {
+			continue
+		}
+		if dst.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+			cmd.setOrigin(name, origin(name))
+		}
+	}
+
+	for k, v := range base.Env {
+		if _, own := cmd.Env[k]; own {
+			continue
+		}
+		if cmd.Env == nil {
+			cmd.Env = make(map[string]string)
+		}
+		cmd.Env[k] = v
+		cmd.setOrigin("env."+k, origin("env."+k))
+	}
+}
+
+// clone returns a copy of c that shares no slices or maps with it, so that
+// expanding the copy leaves c untouched
+func (c CommandConfig) clone() CommandConfig {
+	c.Args = append([]string(nil), c.Args...)
+	c.EnvFiles = append([]string(nil), c.EnvFiles...)
+	c.Secret = append([]string(nil), c.Secret...)
+	c.Params = append([]ParamConfig(nil), c.Params...)
+	if c.Env != nil {
+		env := make(map[string]string, len(c.Env))
+		for k, v := range c.Env {
+			env[k] = v
+		}
+		c.Env = env
+	}
+	if c.origins != nil {
+		origins := make(map[string]string, len(c.origins))
+		for k, v := range c.origins {
+			origins[k] = v
+		}
+		c.origins = origins
+	}
+	for _, steps := range []*[]StepConfig{&c.DependsOn, &c.Steps, &c.Parallel} {
+		if *steps == nil {
+			continue
+		}
+		cloned := make([]StepConfig, len(*steps))
+		for i, step := range *steps {
+			step.CommandConfig = step.CommandConfig.clone()
+			cloned[i] = step
+		}
+		*steps = cloned
+	}
+	return c
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncludesAndTemplates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, filepath.Join(configDir, "common"), "base.yml", `name: Base
+commands:
+  - name: Prune
+    command: docker system prune
+`)
+	writeTestFile(t, filepath.Join(configDir, "common"), "docker.yml", `name: Docker
+include: [base.yml]
+templates:
+  compose:
+    command: docker
+    args: [compose, -f, "${COMPOSE_FILE}", "${ACTION}", "${SERVICE}"]
+    workDir: /srv
+    env:
+      COMPOSE_FILE: compose.yml
+  up:
+    extends: compose
+    env:
+      ACTION: up
+commands:
+  - name: Logs
+    command: docker compose logs
+`)
+	path := writeTestFile(t, filepath.Join(configDir, "ops"), "app.yml", `name: App
+include: [../common/docker.yml]
+commands:
+  - name: Logs
+    command: docker compose logs -f
+  - name: Web
+    extends: up
+    env:
+      SERVICE: web
+  - name: Worker
+    extends: up
+    workDir: /srv/worker
+    env:
+      SERVICE: worker
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	var names []string
+	for _, cmd := range config.Commands {
+		names = append(names, cmd.Name)
+	}
+	if got := strings.Join(names, ", "); got != "Logs, Web, Worker, Prune" {
+		t.Fatalf("Commands = %s, want the included ones after the file's own", got)
+	}
+	if got := config.Commands[0].Command; got != "docker compose logs -f" {
+		t.Errorf("Logs = %q, want the including file's command", got)
+	}
+
+	web, worker := config.Commands[1], config.Commands[2]
+	if got := web.Command + " " + strings.Join(web.Args, " "); got != "docker compose -f compose.yml up web" {
+		t.Errorf("Web = %q, want the template completed with the command's env", got)
+	}
+	if got := strings.Join(worker.Args, " "); got != "compose -f compose.yml up worker" {
+		t.Errorf("Worker args = %q, want its own expansion of the template", got)
+	}
+	if worker.WorkDir != "/srv/worker" || web.WorkDir != "/srv" {
+		t.Errorf("workDir = %q and %q, want the command's own to win", worker.WorkDir, web.WorkDir)
+	}
+	for field, want := range map[string]string{"command": "template compose", "workDir": "template compose", "env.ACTION": "template up", "env.SERVICE": ""} {
+		if got := web.origins[field]; got != want {
+			t.Errorf("origin of %s = %q, want %q", field, got, want)
+		}
+	}
+	if got := config.Templates["compose"].Args[3]; got != "${ACTION}" {
+		t.Errorf("Expected the template to be left unexpanded, got %q", got)
+	}
+}
+
+func TestIncludedCommandsUseTheirOwnFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	setProfile(t, "prod")
+	configDir := filepath.Join(home, ".seli")
+	commonDir := filepath.Join(configDir, "common")
+	writeTestFile(t, commonDir, ".env", "HOST=db.internal\n")
+	writeTestFile(t, commonDir, "db.yml", `name: DB
+dangerous: true
+defaults:
+  timeout: 5m
+profiles:
+  prod:
+    env:
+      DB: shop
+commands:
+  - name: Restore
+    command: restore ${HOST} ${DB}
+    workDir: backups
+  - name: Status
+    command: db status
+    confirm: false
+`)
+	writeTestFile(t, filepath.Join(configDir, "ops"), ".env", "HOST=wrong\nDB=wrong\n")
+	path := writeTestFile(t, filepath.Join(configDir, "ops"), "app.yml", `name: App
+include: [../common/db.yml]
+commands:
+  - name: Logs
+    command: tail -f app.log
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if len(config.Commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(config.Commands))
+	}
+	logs, restore, status := config.Commands[0], config.Commands[1], config.Commands[2]
+
+	if restore.Command != "restore db.internal shop" {
+		t.Errorf("Restore = %q, want the included file's .env and profile", restore.Command)
+	}
+	if got, want := commandDir(restore), filepath.Join(commonDir, "backups"); got != want {
+		t.Errorf("Restore runs in %q, want %q", got, want)
+	}
+	if restore.Timeout != "5m" || logs.Timeout != "" {
+		t.Errorf("timeout = %q and %q, want the included file's defaults on its commands only", restore.Timeout, logs.Timeout)
+	}
+	if !commandConfirm(config, restore).Enabled() {
+		t.Error("Expected a command of a dangerous included file to ask for confirmation")
+	}
+	if got := restore.origins["confirm"]; got != "dangerous" {
+		t.Errorf("origin of confirm = %q, want dangerous", got)
+	}
+	if commandConfirm(config, status).Enabled() || commandConfirm(config, logs).Enabled() {
+		t.Error("Expected confirm: false and the including file's commands to run without confirmation")
+	}
+}
+
+func TestIncludedStepReferences(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	commonDir := filepath.Join(configDir, "common")
+	writeTestFile(t, commonDir, "helper.yml", `name: Helper
+commands:
+  - name: Help
+    command: echo HELP
+`)
+	writeTestFile(t, commonDir, "base.yml", `name: Base
+commands:
+  - name: Build
+    command: echo BASE-BUILD
+  - name: Ship
+    steps:
+      - Build
+      - ref: Help
+        file: helper.yml
+`)
+	path := writeTestFile(t, filepath.Join(configDir, "team"), "app.yml", `name: App
+include: [../common/base.yml]
+commands:
+  - name: Build
+    command: echo APP-BUILD
+  - name: Release
+    steps: [Ship]
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"Ship":    "echo BASE-BUILD (base.yml), echo HELP (helper.yml)",
+		"Release": "echo BASE-BUILD (base.yml), echo HELP (helper.yml)",
+		"Build":   "echo APP-BUILD (app.yml)",
+	} {
+		steps, err := buildPlan(config, *findStepCommand(config, name))
+		if err != nil {
+			t.Fatalf("buildPlan(%s) error = %v", name, err)
+		}
+		var got []string
+		for _, step := range steps {
+			got = append(got, fmt.Sprintf("%s (%s)", step.command.Command, filepath.Base(step.config.Path)))
+		}
+		if strings.Join(got, ", ") != want {
+			t.Errorf("buildPlan(%s) = %s, want %s", name, strings.Join(got, ", "), want)
+		}
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		line  int
+		want  string
+	}{
+		{
+			"cycle",
+			map[string]string{
+				"a.yml": "name: A\ninclude:\n  - b.yml\ncommands: []\n",
+				"b.yml": "name: B\ninclude: [a.yml]\ncommands: []\n",
+			},
+			3, `include "b.yml": ~/.seli/cycle/b.yml:2:11: include cycle: ~/.seli/cycle/a.yml -> ~/.seli/cycle/b.yml -> ~/.seli/cycle/a.yml`,
+		},
+		{
+			"missing file",
+			map[string]string{"a.yml": "name: A\ninclude: [gone.yml]\ncommands: []\n"},
+			2, `include "gone.yml": failed to read file`,
+		},
+		{
+			"error in a nested include",
+			map[string]string{
+				"a.yml": "name: A\ninclude: [b.yml]\ncommands: []\n",
+				"b.yml": "name: B\ninclude: [c.yml]\ncommands: []\n",
+				"c.yml": "name: C\ncommands:\n  - name: X\n    comand: x\n",
+			},
+			2, `include "b.yml": ~/.seli/error-in-a-nested-include/b.yml:2:11: include "c.yml": ~/.seli/error-in-a-nested-include/c.yml:4:5: unknown field "comand"`,
+		},
+		{
+			"template cycle",
+			map[string]string{"a.yml": "name: A\ntemplates:\n  base:\n    extends: child\n  child:\n    extends: base\ncommands:\n  - name: Run\n    extends: base\n"},
+			8, `command "Run": template cycle: base -> child -> base`,
+		},
+		{
+			"unknown template",
+			map[string]string{"a.yml": "name: A\ntemplates:\n  base:\n    command: x\ncommands:\n  - name: Run\n    extends: bsae\n"},
+			6, `extends unknown template "bsae" (available: base)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(configDir, strings.ReplaceAll(tt.name, " ", "-"))
+			for name, content := range tt.files {
+				writeTestFile(t, dir, name, content)
+			}
+
+			_, err := LoadConfigFile(filepath.Join(dir, "a.yml"), "")
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) || filepath.Base(configErr.Path) != "a.yml" || configErr.Pos.Line != tt.line {
+				t.Fatalf("LoadConfigFile() error = %v, want an error on line %d of a.yml", err, tt.line)
+			}
+			if got := strings.ReplaceAll(configErr.Err.Error(), home, "~"); !strings.Contains(got, tt.want) {
+				t.Errorf("LoadConfigFile() error = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
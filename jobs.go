@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxJobLog is the number of output bytes kept per job
+const maxJobLog = 256 * 1024
+
+// jobRefreshInterval is how often the Jobs panel updates uptimes and logs
+const jobRefreshInterval = 500 * time.Millisecond
+
+// jobLog collects the output of a job, keeping only the most recent bytes
+type jobLog struct {
+	mu  sync.Mutex
+	buf []byte
+	// secrets are masked when the log is read
+	secrets []string
+}
+
+// Write appends p to the log
+func (l *jobLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	if len(l.buf) > maxJobLog {
+		l.buf = append([]byte(nil), l.buf[len(l.buf)-maxJobLog:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the collected output with the secrets masked
+func (l *jobLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return maskSecrets(string(l.buf), l.secrets)
+}
+
+// Job is a command running in the background while the TUI stays open
+type Job struct {
+	ID      int
+	Name    string
+	Command CommandConfig
+	Started time.Time
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	log      *jobLog
+	done     chan struct{}
+	finished time.Time
+	err      error
+	stopping bool
+	// timedOut is set when the job was stopped after its timeout
+	timedOut bool
+	// restarting is set while Restart waits for the old process to exit
+	restarting bool
+}
+
+// Running reports whether the job has not exited yet
+func (j *Job) Running() bool {
+	j.mu.Lock()
+	done := j.done
+	j.mu.Unlock()
+
+	select {
+	case <-done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Output returns the captured stdout and stderr of the job
+func (j *Job) Output() string {
+	j.mu.Lock()
+	log := j.log
+	j.mu.Unlock()
+	return log.String()
+}
+
+// Status describes the job, e.g. "running · PID 4242 · up 3m12s" or
+// "exit 1 · ran 5s"
+func (j *Job) Status(now time.Time) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.finished.IsZero() {
+		ran := j.finished.Sub(j.Started).Round(time.Second)
+		code, fromChild := ExitCode(j.err)
+		switch {
+		case j.timedOut:
+			return fmt.Sprintf("timed out · ran %s", ran)
+		case j.stopping:
+			return fmt.Sprintf("stopped · ran %s", ran)
+		case j.err != nil && !fromChild:
+			return fmt.Sprintf("failed: %v", j.err)
+		}
+		return fmt.Sprintf("exit %d · ran %s", code, ran)
+	}
+	status := fmt.Sprintf("running · PID %d · up %s", j.cmd.Process.Pid, now.Sub(j.Started).Round(time.Second))
+	if j.stopping {
+		status = "stopping · " + status
+	}
+	return status
+}
+
+// Stop asks the job's process group to exit and kills it if it is still
+// running after stopTimeout
+func (j *Job) Stop() {
+	if !j.Running() {
+		return
+	}
+	j.mu.Lock()
+	j.stopping = true
+	cmd, done := j.cmd, j.done
+	j.mu.Unlock()
+	stopJobProcess(cmd, done)
+}
+
+// stopJobProcess sends SIGTERM to the process group of cmd and kills it if
+// done is not closed within stopTimeout
+func stopJobProcess(cmd *exec.Cmd, done <-chan struct{}) {
+	_ = signalProcessGroup(cmd, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(stopTimeout):
+			_ = signalProcessGroup(cmd, syscall.SIGKILL)
+		}
+	}()
+}
+
+// Wait blocks until the job has exited
+func (j *Job) Wait() {
+	j.mu.Lock()
+	done := j.done
+	j.mu.Unlock()
+	<-done
+}
+
+// JobManager starts and keeps track of background jobs
+type JobManager struct {
+	mu       sync.Mutex
+	executor *CommandExecutor
+	jobs     []*Job
+	nextID   int
+}
+
+// NewJobManager creates a job manager that starts commands with executor
+func NewJobManager(executor *CommandExecutor) *JobManager {
+	return &JobManager{executor: executor, nextID: 1}
+}
+
+// Start runs cmd in the background in its own process group, capturing its
+// output. Steps, dependencies and parallel groups cannot run as jobs.
+func (jm *JobManager) Start(cmd CommandConfig) (*Job, error) {
+	if cmd.isChain() || len(cmd.Parallel) > 0 {
+		return nil, fmt.Errorf("command %q has steps and cannot run in the background", cmd.Name)
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job := &Job{ID: jm.nextID, Name: cmd.Name, Command: cmd}
+	if err := jm.launch(job); err != nil {
+		return nil, err
+	}
+	jm.nextID++
+	jm.jobs = append(jm.jobs, job)
+	return job, nil
+}
+
+// launch starts the process of job with a fresh log
+func (jm *JobManager) launch(job *Job) error {
+	command, secrets, err := resolveSecrets(job.Command)
+	if err != nil {
+		return err
+	}
+	log := &jobLog{secrets: secrets}
+	cmd, err := jm.executor.ExecuteCommandInBackground(command, withOutput(log, log), withProcessGroup())
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	job.mu.Lock()
+	job.cmd, job.log, job.done = cmd, log, done
+	job.Started, job.finished, job.err, job.stopping, job.timedOut = time.Now(), time.Time{}, nil, false, false
+	job.mu.Unlock()
+
+	if timeout := command.timeout(); timeout > 0 {
+		go func() {
+			select {
+			case <-done:
+			case <-time.After(timeout):
+				job.mu.Lock()
+				job.timedOut = true
+				job.mu.Unlock()
+				stopJobProcess(cmd, done)
+			}
+		}()
+	}
+
+	go func() {
+		err := cmd.Wait()
+		job.mu.Lock()
+		job.finished, job.err = time.Now(), err
+		job.mu.Unlock()
+		close(done)
+	}()
+	return nil
+}
+
+// Restart stops the job if it is running and starts its command again. It
+// blocks until the old process has exited; a restart requested while another
+// one is in progress is ignored.
+func (jm *JobManager) Restart(job *Job) error {
+	job.mu.Lock()
+	if job.restarting {
+		job.mu.Unlock()
+		return nil
+	}
+	job.restarting = true
+	job.mu.Unlock()
+	defer func() {
+		job.mu.Lock()
+		job.restarting = false
+		job.mu.Unlock()
+	}()
+
+	job.Stop()
+	job.Wait()
+	return jm.launch(job)
+}
+
+// Jobs returns every job started so far, oldest first
+func (jm *JobManager) Jobs() []*Job {
+	if jm == nil {
+		return nil
+	}
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	return append([]*Job(nil), jm.jobs...)
+}
+
+// Running returns the number of jobs that have not exited
+func (jm *JobManager) Running() int {
+	running := 0
+	for _, job := range jm.Jobs() {
+		if job.Running() {
+			running++
+		}
+	}
+	return running
+}
+
+// StopAll stops every running job and waits for them to exit
+func (jm *JobManager) StopAll() {
+	jobs := jm.Jobs()
+	for _, job := range jobs {
+		job.Stop()
+	}
+	for _, job := range jobs {
+		job.Wait()
+	}
+}
+
+// jobExitedMsg is sent when a background job exits
+type jobExitedMsg struct {
+	job *Job
+}
+
+// jobRestartedMsg is sent when a restart of a job has completed
+type jobRestartedMsg struct {
+	job *Job
+	err error
+}
+
+// jobTickMsg refreshes the Jobs panel. Ticks of an earlier panel are ignored.
+type jobTickMsg struct {
+	id int
+}
+
+// waitForJob returns a tea.Cmd that reports when job exits
+func waitForJob(job *Job) tea.Cmd {
+	job.mu.Lock()
+	done := job.done
+	job.mu.Unlock()
+	return func() tea.Msg {
+		<-done
+		return jobExitedMsg{job: job}
+	}
+}
+
+// restartJob returns a tea.Cmd that restarts job, so that waiting for a job
+// that ignores SIGTERM does not freeze the TUI
+func restartJob(jm *JobManager, job *Job) tea.Cmd {
+	return func() tea.Msg {
+		return jobRestartedMsg{job: job, err: jm.Restart(job)}
+	}
+}
+
+// jobTick schedules the next refresh of the Jobs panel
+func jobTick(id int) tea.Cmd {
+	return tea.Tick(jobRefreshInterval, func(time.Time) tea.Msg {
+		return jobTickMsg{id: id}
+	})
+}
+
+// startJob runs cmd as a background job and returns to the list
+func (m Model) startJob(cmd CommandConfig) (Model, tea.Cmd) {
+	if m.state == stateCollectingParams {
+		m.state = m.formReturn
+	}
+	job, err := m.jobs.Start(cmd)
+	if err != nil {
+		return m.showError(err), nil
+	}
+	return m, waitForJob(job)
+}
+
+// openJobs shows the Jobs panel
+func (m Model) openJobs() (Model, tea.Cmd) {
+	m = m.saveView()
+	m.state = stateJobs
+	m.list.Title = titleStyle.Render("Jobs")
+	m.list.SetItems(jobItems(m.jobs.Jobs(), time.Now()))
+	m.jobTick++
+	return m, jobTick(m.jobTick)
+}
+
+// attachJob shows the output of the selected job, following new output
+func (m Model) attachJob() (Model, tea.Cmd) {
+	job := m.selectedJob()
+	if job == nil {
+		return m, nil
+	}
+	m.state = stateJobLog
+	m.attached = job
+	m.viewport.SetContent(job.Output())
+	m.viewport.GotoBottom()
+	m.jobTick++
+	return m, jobTick(m.jobTick)
+}
+
+// selectedJob returns the job selected in the Jobs panel, or the attached job
+func (m Model) selectedJob() *Job {
+	if m.state == stateJobLog {
+		return m.attached
+	}
+	if item, ok := m.list.SelectedItem().(Item); ok {
+		return item.job
+	}
+	return nil
+}
+
+// refreshJobs updates the Jobs panel or the attached log. The log keeps
+// following new output unless it has been scrolled up.
+func (m Model) refreshJobs() Model {
+	switch m.state {
+	case stateJobs:
+		index := m.list.Index()
+		m.list.SetItems(jobItems(m.jobs.Jobs(), time.Now()))
+		m.list.Select(index)
+	case stateJobLog:
+		follow := m.viewport.AtBottom()
+		m.viewport.SetContent(m.attached.Output())
+		if follow {
+			m.viewport.GotoBottom()
+		}
+	}
+	return m
+}
+
+// updateJobs handles keys in the Jobs panel and the attached log
+func (m Model) updateJobs(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, nil, false
+	case tea.KeyEsc, tea.KeyBackspace:
+		return m.closeJobView(), nil, true
+	case tea.KeyEnter:
+		if m.state == stateJobs {
+			m, cmd := m.attachJob()
+			return m, cmd, true
+		}
+	case tea.KeyRunes:
+		switch string(msg.Runes) {
+		case "q":
+			return m.closeJobView(), nil, true
+		case "a":
+			if m.state == stateJobs {
+				m, cmd := m.attachJob()
+				return m, cmd, true
+			}
+		case "s":
+			if job := m.selectedJob(); job != nil {
+				job.Stop()
+			}
+			return m.refreshJobs(), nil, true
+		case "r":
+			job := m.selectedJob()
+			if job == nil {
+				return m, nil, true
+			}
+			return m, restartJob(m.jobs, job), true
+		}
+	}
+
+	if m.state == stateJobLog {
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+// closeJobView returns from the attached log to the Jobs panel, and from the
+// Jobs panel to the list it was opened from
+func (m Model) closeJobView() Model {
+	if m.state == stateJobLog {
+		m.state = stateJobs
+		m.attached = nil
+		return m.refreshJobs()
+	}
+	return m.restoreView()
+}
+
+// jobItems creates list items for the Jobs panel
+func jobItems(jobs []*Job, now time.Time) []list.Item {
+	items := make([]list.Item, len(jobs))
+	for i, job := range jobs {
+		items[i] = Item{
+			title:       fmt.Sprintf("[%d] %s", job.ID, job.Name),
+			description: job.Status(now),
+			job:         job,
+		}
+	}
+	return items
+}
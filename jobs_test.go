@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// waitForOutput polls the job output until it contains want
+func waitForOutput(t *testing.T, job *Job, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(job.Output(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected job output to contain %q, got %q", want, job.Output())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobManager(t *testing.T) {
+	jobs := NewJobManager(NewCommandExecutor())
+	defer jobs.StopAll()
+
+	if _, err := jobs.Start(CommandConfig{Name: "Chain", Steps: []StepConfig{{Ref: "Build"}}}); err == nil {
+		t.Error("Expected a command with steps to be rejected")
+	}
+
+	job, err := jobs.Start(CommandConfig{Name: "Server", Command: "echo started; sleep 30", Shell: shellDefault})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitForOutput(t, job, "started")
+	if !job.Running() || !strings.HasPrefix(job.Status(time.Now()), "running · PID ") {
+		t.Errorf("Expected the job to be running, got %q", job.Status(time.Now()))
+	}
+
+	job.Stop()
+	job.Wait()
+	if status := job.Status(time.Now()); !strings.HasPrefix(status, "stopped") {
+		t.Errorf("Expected the job to be stopped, got %q", status)
+	}
+
+	if err := jobs.Restart(job); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	if !job.Running() || jobs.Running() != 1 {
+		t.Error("Expected the job to run again after a restart")
+	}
+
+	quick, err := jobs.Start(CommandConfig{Name: "Fail", Command: "sh -c 'exit 3'"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	quick.Wait()
+	if status := quick.Status(time.Now()); !strings.HasPrefix(status, "exit 3") {
+		t.Errorf("Expected the exit status to be shown, got %q", status)
+	}
+	if quick.ID != 2 {
+		t.Errorf("Expected job IDs to increase, got %d", quick.ID)
+	}
+}
+
+func TestJobsPanel(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFile(t, filepath.Join(home, ".seli"), "dev.yml", "name: Dev\nshell: true\ncommands:\n  - name: Server\n    command: echo serving; sleep 30\n")
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	defer model.jobs.StopAll()
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if model.state != stateViewingCommands {
+		t.Fatalf("Expected the single config to open directly, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	if model.state != stateViewingCommands || model.jobs.Running() != 1 {
+		t.Fatalf("Expected the command to start in the background, got state %v with %d jobs", model.state, model.jobs.Running())
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("J")})
+	if model.state != stateJobs || len(model.list.Items()) != 1 {
+		t.Fatalf("Expected the Jobs panel to list one job, got state %v", model.state)
+	}
+
+	job := model.list.SelectedItem().(Item).job
+	waitForOutput(t, job, "serving")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateJobLog || !strings.Contains(model.View(), "serving") {
+		t.Fatalf("Expected the job log to be shown, got state %v", model.state)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	job.Wait()
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if model.state != stateJobs || !strings.HasPrefix(model.list.SelectedItem().(Item).description, "stopped") {
+		t.Errorf("Expected the stopped job in the Jobs panel, got state %v", model.state)
+	}
+
+	// The restart runs in a tea.Cmd and reports back with a message
+	updated, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	model = updated.(Model)
+	if cmd == nil || job.Running() {
+		t.Fatal("Expected the restart to be left to a tea.Cmd")
+	}
+	msg, ok := cmd().(jobRestartedMsg)
+	if !ok || msg.err != nil || !job.Running() {
+		t.Fatalf("Expected the job to run again, got %+v", msg)
+	}
+	send(msg)
+	if !strings.HasPrefix(model.list.SelectedItem().(Item).description, "running") {
+		t.Errorf("Expected the restarted job in the Jobs panel, got %q", model.list.SelectedItem().(Item).description)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.state != stateViewingCommands || model.list.SelectedItem().(Item).title != "Server" {
+		t.Errorf("Expected to return to the command list, got state %v", model.state)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+// stayInTUI is the global stay setting, enabled with --stay or SELI_STAY
+var stayInTUI bool
+
+// commandFinishedMsg is sent when a command run from the TUI has finished
+type commandFinishedMsg struct {
+	err error
+}
+
+// launchCommand runs a command while the TUI is suspended. It implements
+// tea.ExecCommand so that the executor's chains, parallel groups and history
+// recording behave exactly as when seli exits before running the command.
+type launchCommand struct {
+	executor *CommandExecutor
+	config   *ConfigFile
+	command  CommandConfig
+	params   map[string]string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func (c *launchCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *launchCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *launchCommand) SetStderr(io.Writer)   {}
+
+// Run executes the command and waits for a key press, so that its output
+// stays visible until the user returns to the list
+func (c *launchCommand) Run() error {
+	err := executeAndRecord(c.executor, c.config, c.command, c.params)
+	code := commandExitCode(err)
+
+	fmt.Fprintf(c.stdout, "\nexit code %d, press any key to return to seli", code)
+	waitForKey(c.stdin)
+	fmt.Fprintln(c.stdout)
+	return err
+}
+
+// waitForKey reads a single key press from r. On a terminal the key is read
+// in raw mode so that it does not need to be followed by Enter.
+func waitForKey(r io.Reader) {
+	if f, ok := r.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		if state, err := term.MakeRaw(f.Fd()); err == nil {
+			defer term.Restore(f.Fd(), state)
+		}
+	}
+	_, _ = r.Read(make([]byte, 1))
+}
+
+// runInTUI returns the tea.Cmd that suspends the TUI and runs cmd
+func (m Model) runInTUI(cmd CommandConfig) tea.Cmd {
+	launch := &launchCommand{
+		executor: m.executor,
+		config:   m.currentConfig,
+		command:  cmd,
+		params:   m.pendingParams,
+	}
+	return tea.Exec(launch, func(err error) tea.Msg {
+		return commandFinishedMsg{err: err}
+	})
+}
+
+// shouldStay reports whether commands of the current config file run without
+// leaving the TUI. The file's stay setting overrides the global one.
+func (m Model) shouldStay() bool {
+	if m.currentConfig != nil && m.currentConfig.Stay != nil {
+		return *m.currentConfig.Stay
+	}
+	return m.stay
+}
+
+// stayFromEnv reads the global stay setting from SELI_STAY
+func stayFromEnv() bool {
+	stay, _ := strconv.ParseBool(os.Getenv("SELI_STAY"))
+	return stay
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStayInTUI(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SELI_STAY", "1")
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, "a.yml", "name: A\ncommands:\n  - name: Build\n    command: make\n  - name: Test\n    command: make test\n")
+	writeTestFile(t, configDir, "b.yml", "name: B\nstay: false\ncommands:\n  - name: Lint\n    command: make lint\n")
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	send := func(msg tea.Msg) tea.Cmd {
+		updated, cmd := model.Update(msg)
+		model = updated.(Model)
+		return cmd
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	send(tea.KeyMsg{Type: tea.KeyDown})
+	cmd := send(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("Expected a command to run the selection")
+	}
+	if _, quit := cmd().(tea.QuitMsg); quit {
+		t.Fatal("Expected seli to stay open with SELI_STAY=1")
+	}
+	if model.state != stateViewingCommands || model.list.Index() != 1 {
+		t.Errorf("Expected to stay at the same list position, got state %v index %d", model.state, model.list.Index())
+	}
+
+	send(commandFinishedMsg{})
+	if model.pending != nil {
+		t.Error("Expected no pending command after it finished")
+	}
+
+	// The file's stay setting overrides the global one
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	model.list.Select(1)
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateExecutingCommand || model.pending.Name != "Lint" {
+		t.Errorf("Expected seli to exit for a file with stay: false, got state %v", model.state)
+	}
+}
+
+func TestLaunchCommandRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var out bytes.Buffer
+	launch := &launchCommand{
+		executor: NewCommandExecutor(),
+		config:   &ConfigFile{Name: "A", Path: filepath.Join(t.TempDir(), "a.yml")},
+		command:  CommandConfig{Name: "Fail", Command: "sh -c 'exit 3'"},
+	}
+	launch.SetStdin(strings.NewReader("x"))
+	launch.SetStdout(&out)
+
+	err := launch.Run()
+	if code, _ := ExitCode(err); code != 3 {
+		t.Errorf("Expected exit code 3, got %d (%v)", code, err)
+	}
+	if !strings.Contains(out.String(), "exit code 3, press any key") {
+		t.Errorf("Expected the exit code prompt, got %q", out.String())
+	}
+}
@@ -2,17 +2,132 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	os.Exit(runMain(os.Args[1:]))
+}
+
+// runMain dispatches to the TUI or to one of the non-interactive subcommands
+// and returns the process exit code
+func runMain(args []string) int {
+	args, err := parseGlobalFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	if activeProfile == "" {
+		activeProfile = profileFromEnv()
+	}
+
+	if len(args) == 0 {
+		return runTUI()
+	}
+
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:])
+	case "list", "ls":
+		return listCommands(args[1:])
+	case "history":
+		return showHistory(args[1:])
+	case "last":
+		return runLast(args[1:])
+	case "validate":
+		return validateCommand(args[1:])
+	case "schema":
+		return schemaCommand(args[1:])
+	case "explain":
+		return explainCommand(args[1:])
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+}
+
+// parseGlobalFlags consumes the flags that precede the subcommand and returns
+// the remaining arguments
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 {
+		switch {
+		case args[0] == "--config":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("flag needs an argument: --config")
+			}
+			configRootFlags = append(configRootFlags, args[1])
+			args = args[2:]
+		case strings.HasPrefix(args[0], "--config="):
+			configRootFlags = append(configRootFlags, strings.TrimPrefix(args[0], "--config="))
+			args = args[1:]
+		case args[0] == "--profile":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("flag needs an argument: --profile")
+			}
+			activeProfile = args[1]
+			args = args[2:]
+		case strings.HasPrefix(args[0], "--profile="):
+			activeProfile = strings.TrimPrefix(args[0], "--profile=")
+			args = args[1:]
+		case args[0] == "--stay":
+			stayInTUI = true
+			args = args[1:]
+		default:
+			return args, nil
+		}
+	}
+	return args, nil
+}
+
+// printUsage prints the command line help
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+  seli [--config PATH]... [--profile NAME] [--stay] [command]
+  seli                              Start the interactive launcher
+  seli run [--yes] [--param NAME=VALUE]... <file> <command>
+                                    Run a configured command without the TUI
+  seli list [--json]                List every config file and command
+  seli history [--json] [-n N]      Show recent executions, most recent first
+  seli last [--yes] [N]             Run the most recent (or Nth most recent) execution again
+  seli validate [path]              Check config files and report problems with their line
+  seli schema                       Print the JSON Schema of config files
+  seli explain <file> <command>     Print the effective settings of a command and where they come from
+
+The <file> of "seli run" is a path relative to ~/.seli (the extension may be
+omitted) or to the current directory. The <command> is matched by exact name,
+by slug ("deploy-staging") or by an unambiguous fuzzy match.
+
+Config roots: --config (repeatable) or SELI_CONFIG_DIR (colon-separated,
+semicolon-separated on Windows) replace ~/.seli; $XDG_CONFIG_HOME/seli is used
+when ~/.seli does not exist. Project .seli/ directories and seli.yml files are
+always included.
+
+--profile NAME (or SELI_PROFILE) selects the environment profile of config
+files that define it; in the launcher, p switches profiles.
+
+--stay (or SELI_STAY=1) keeps the launcher open: commands run with the TUI
+suspended and seli returns to the list afterwards. A config file's "stay"
+setting overrides it.
+`)
+}
+
+// runTUI starts the bubble tea program and runs the selected command after it exits
+func runTUI() int {
 	// Create initial model
 	initialModel, err := InitialModel()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing application: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Start the bubble tea program
@@ -20,23 +135,30 @@ func main() {
 	finalModel, err := p.Run()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	// Handle command execution after TUI exits
+	// Background jobs do not outlive the TUI
 	model := finalModel.(Model)
-	if model.state == stateExecutingCommand && model.currentConfig != nil {
-		selectedItem := model.list.SelectedItem()
-		if selectedItem != nil {
-			item := selectedItem.(Item)
-			if item.isCommand && item.command != nil {
-				// Execute the command (show details will be handled inside ExecuteCommand)
-				err := model.executor.ExecuteCommand(*item.command, model.currentConfig.Show)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error executing command: %v\n", err)
-					os.Exit(1)
-				}
-			}
-		}
+	model.jobs.StopAll()
+
+	// Handle command execution after TUI exits
+	if model.state == stateExecutingCommand && model.currentConfig != nil && model.pending != nil {
+		// Execute the command (show details will be handled inside ExecuteCommand)
+		err := executeAndRecord(model.executor, model.currentConfig, *model.pending, model.pendingParams)
+		return commandExitCode(err)
+	}
+
+	return 0
+}
+
+// commandExitCode returns the exit code for the result of ExecuteCommand. The
+// child's own status is passed through silently; only failures to launch the
+// command are reported by seli itself.
+func commandExitCode(err error) int {
+	code, fromChild := ExitCode(err)
+	if err != nil && !fromChild {
+		fmt.Fprintf(os.Stderr, "Error executing command: %v\n", err)
 	}
+	return code
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// stopTimeout is how long a parallel command may take to exit after SIGTERM
+// before it is killed
+const stopTimeout = 5 * time.Second
+
+// prefixColors are cycled through for the output prefixes of parallel commands
+var prefixColors = []lipgloss.Color{"#5FAFFF", "#FFAF5F", "#AF87FF", "#5FD787", "#FF87AF", "#D7D75F"}
+
+// BackgroundOption configures a command started by ExecuteCommandInBackground
+type BackgroundOption func(*exec.Cmd)
+
+// withOutput connects stdout and stderr of the command
+func withOutput(stdout, stderr io.Writer) BackgroundOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
+}
+
+// withProcessGroup starts the command in its own process group
+func withProcessGroup() BackgroundOption {
+	return setProcessGroup
+}
+
+// prefixWriter writes every complete line it receives to out, preceded by
+// prefix. Writers sharing a mutex never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+// Write buffers p and writes out the complete lines
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:i]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line without newline
+func (w *prefixWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf)
+		w.buf = nil
+	}
+}
+
+// parallelExit is the result of one parallel command
+type parallelExit struct {
+	index int
+	err   error
+}
+
+// ExecuteParallel starts the commands of a parallel group at the same time
+// and prefixes each line of their output with the command name. Unless
+// onError is "continue", the first failing command stops all others; otherwise
+// every command runs to completion. Signals received by seli are forwarded to
+// the process group of every command. A command that runs longer than its
+// timeout is stopped like a failing one, and the timeout of the group stops
+// every command. The error of the first failing command is returned.
+func (e *CommandExecutor) ExecuteParallel(config *ConfigFile, cmd CommandConfig) error {
+	config = cmd.stepsConfig(config)
+	p := &planner{configs: map[string]*ConfigFile{config.Path: config}}
+	commands := make([]CommandConfig, len(cmd.Parallel))
+	width := 0
+	for i, entry := range cmd.Parallel {
+		_, command, err := p.resolve(config, entry)
+		if err != nil {
+			return fmt.Errorf("command %q: parallel command %q: %w", cmd.Name, entry.label(), err)
+		}
+		if command.isChain() || len(command.Parallel) > 0 {
+			return fmt.Errorf("command %q: parallel command %q must be a single command", cmd.Name, command.Name)
+		}
+		commands[i] = command
+		if len(command.Name) > width {
+			width = len(command.Name)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	var mu sync.Mutex
+	results := make([]StepResult, len(commands))
+	running := make([]*exec.Cmd, len(commands))
+	killTimers := make([]*time.Timer, len(commands))
+	writers := make([]*prefixWriter, 0, 2*len(commands))
+	exits := make(chan parallelExit, len(commands))
+	// timeouts receives the index of a command whose timeout expired, or -1
+	// when the timeout of the whole group did
+	timeouts := make(chan int, len(commands)+1)
+	timedOut := make([]bool, len(commands))
+	start := time.Now()
+	if timeout := cmd.timeout(); timeout > 0 {
+		defer time.AfterFunc(timeout, func() { timeouts <- -1 }).Stop()
+	}
+
+	for i, command := range commands {
+		style := lipgloss.NewStyle().Foreground(prefixColors[i%len(prefixColors)])
+		prefix := style.Render(fmt.Sprintf("%-*s |", width, command.Name)) + " "
+		stdout := &prefixWriter{mu: &mu, out: os.Stdout, prefix: prefix}
+		stderr := &prefixWriter{mu: &mu, out: os.Stderr, prefix: prefix}
+		writers = append(writers, stdout, stderr)
+
+		results[i].Name = command.Name
+		resolved, _, err := resolveSecrets(command)
+		var child *exec.Cmd
+		if err == nil {
+			child, err = e.ExecuteCommandInBackground(resolved, withOutput(stdout, stderr), withProcessGroup())
+		}
+		if err != nil {
+			exits <- parallelExit{index: i, err: err}
+			continue
+		}
+		running[i] = child
+		go func(i int, child *exec.Cmd) {
+			exits <- parallelExit{index: i, err: child.Wait()}
+		}(i, child)
+		if timeout := command.timeout(); timeout > 0 {
+			defer time.AfterFunc(timeout, func() { timeouts <- i }).Stop()
+		}
+	}
+
+	// stopAll asks every running command to exit and kills it if it does not
+	stopAll := func(sig os.Signal) {
+		for i, child := range running {
+			if child == nil {
+				continue
+			}
+			results[i].Stopped = true
+			_ = signalProcessGroup(child, sig)
+			child := child
+			killTimers[i] = time.AfterFunc(stopTimeout, func() { _ = signalProcessGroup(child, syscall.SIGKILL) })
+		}
+	}
+
+	var firstErr error
+	for remaining := len(commands); remaining > 0; {
+		select {
+		case sig := <-signals:
+			for _, child := range running {
+				if child != nil {
+					_ = signalProcessGroup(child, sig)
+				}
+			}
+
+		case i := <-timeouts:
+			if i < 0 {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("timed out after %s", cmd.timeout())
+				}
+				stopAll(syscall.SIGTERM)
+				continue
+			}
+			if child := running[i]; child != nil {
+				timedOut[i] = true
+				_ = signalProcessGroup(child, syscall.SIGTERM)
+				killTimers[i] = time.AfterFunc(stopTimeout, func() { _ = signalProcessGroup(child, syscall.SIGKILL) })
+			}
+
+		case exit := <-exits:
+			remaining--
+			running[exit.index] = nil
+			if killTimers[exit.index] != nil {
+				killTimers[exit.index].Stop()
+			}
+			if timedOut[exit.index] {
+				exit.err = fmt.Errorf("timed out after %s: %w", commands[exit.index].timeout(), exit.err)
+			}
+			results[exit.index].Duration = time.Since(start)
+			results[exit.index].Err = exit.err
+			if exit.err == nil || firstErr != nil {
+				continue
+			}
+			firstErr = fmt.Errorf("parallel command %q failed: %w", commands[exit.index].Name, exit.err)
+			if cmd.OnError != onErrorContinue {
+				stopAll(syscall.SIGTERM)
+			}
+		}
+	}
+
+	for _, w := range writers {
+		w.Flush()
+	}
+	printStepSummary(results)
+	return firstErr
+}
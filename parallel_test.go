@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var mu sync.Mutex
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &mu, out: &out, prefix: "api | "}
+
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\nthird"))
+	if out.String() != "api | first\napi | second\n" {
+		t.Errorf("Expected only complete lines to be written, got %q", out.String())
+	}
+
+	w.Flush()
+	if out.String() != "api | first\napi | second\napi | third\n" {
+		t.Errorf("Expected Flush to write the trailing line, got %q", out.String())
+	}
+}
+
+func TestExecuteParallelStopsAllOnFailure(t *testing.T) {
+	config := &ConfigFile{Name: "Dev", Path: filepath.Join(t.TempDir(), "dev.yml")}
+	group := CommandConfig{
+		Name: "Dev",
+		Parallel: []StepConfig{
+			// The grandchild keeps the pipe open unless the whole process group is stopped
+			{CommandConfig: CommandConfig{Name: "Server", Command: "sleep 30 & wait", Shell: shellDefault}},
+			{CommandConfig: CommandConfig{Name: "Broken", Command: "sh -c 'sleep 0.1; exit 2'"}},
+		},
+	}
+
+	start := time.Now()
+	err := NewCommandExecutor().ExecuteParallel(config, group)
+	if code, _ := ExitCode(err); code != 2 {
+		t.Errorf("Expected exit code 2 from the failing command, got %d (%v)", code, err)
+	}
+	if elapsed := time.Since(start); elapsed > stopTimeout {
+		t.Errorf("Expected the other commands to be stopped, took %s", elapsed)
+	}
+}
+
+func TestExecuteParallelWaitsForAll(t *testing.T) {
+	dir := t.TempDir()
+	config := &ConfigFile{Name: "Dev", Path: filepath.Join(dir, "dev.yml")}
+	config.Commands = []CommandConfig{{Name: "Slow", Command: "sh -c 'sleep 0.3; touch done'", WorkDir: dir}}
+	group := CommandConfig{
+		Name:    "Dev",
+		OnError: onErrorContinue,
+		Parallel: []StepConfig{
+			{Ref: "Slow"},
+			{CommandConfig: CommandConfig{Name: "Broken", Command: "false"}},
+		},
+	}
+
+	if err := NewCommandExecutor().ExecuteParallel(config, group); err == nil {
+		t.Error("Expected the failing command to be reported")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "done")); err != nil {
+		t.Error("Expected the other command to run to completion with onError: continue")
+	}
+}
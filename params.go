@@ -0,0 +1,451 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parameter types
+const (
+	paramString   = "string"
+	paramChoice   = "choice"
+	paramBool     = "bool"
+	paramInt      = "int"
+	paramPassword = "password"
+)
+
+// ParamConfig describes a value collected from the user before a command runs.
+// It is referenced in command fields as ${param.NAME}.
+type ParamConfig struct {
+	Name           string   `json:"name" yaml:"name" toml:"name"`
+	Prompt         string   `json:"prompt,omitempty" yaml:"prompt,omitempty" toml:"prompt,omitempty"`
+	Default        string   `json:"default,omitempty" yaml:"default,omitempty" toml:"default,omitempty"`
+	Type           string   `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	Validate       string   `json:"validate,omitempty" yaml:"validate,omitempty" toml:"validate,omitempty"`
+	Choices        []string `json:"choices,omitempty" yaml:"choices,omitempty" toml:"choices,omitempty"`
+	ChoicesCommand string   `json:"choicesCommand,omitempty" yaml:"choicesCommand,omitempty" toml:"choicesCommand,omitempty"`
+}
+
+var (
+	paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	paramRefPattern  = regexp.MustCompile(`\$\{param\.([^}]*)\}`)
+)
+
+// Kind returns the parameter type, defaulting to string
+func (p ParamConfig) Kind() string {
+	if p.Type == "" {
+		return paramString
+	}
+	return p.Type
+}
+
+// Label returns the text shown when asking for the parameter
+func (p ParamConfig) Label() string {
+	if p.Prompt != "" {
+		return p.Prompt
+	}
+	return p.Name
+}
+
+// check verifies that the parameter declaration itself is valid
+func (p ParamConfig) check() error {
+	if !paramNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid parameter name %q", p.Name)
+	}
+	switch p.Kind() {
+	case paramString, paramBool, paramInt, paramPassword:
+	case paramChoice:
+		if len(p.Choices) == 0 && p.ChoicesCommand == "" {
+			return fmt.Errorf("parameter %q: choice parameters need choices or choicesCommand", p.Name)
+		}
+	default:
+		return fmt.Errorf("parameter %q: unknown type %q", p.Name, p.Type)
+	}
+	if p.Validate != "" {
+		if _, err := regexp.Compile(p.Validate); err != nil {
+			return fmt.Errorf("parameter %q: invalid validate pattern: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Check verifies a value entered for the parameter. choices are the resolved
+// choices of a choice parameter.
+func (p ParamConfig) Check(value string, choices []string) error {
+	switch p.Kind() {
+	case paramInt:
+		if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%s must be an integer", p.Label())
+		}
+	case paramBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", p.Label())
+		}
+	case paramChoice:
+		found := false
+		for _, choice := range choices {
+			if choice == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of: %s", p.Label(), strings.Join(choices, ", "))
+		}
+	}
+
+	if p.Validate != "" {
+		re, err := regexp.Compile(p.Validate)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s does not match %s", p.Label(), p.Validate)
+		}
+	}
+	return nil
+}
+
+// LoadChoices returns the choices of a choice parameter, running its
+// choicesCommand in the command's working directory and environment
+func (p ParamConfig) LoadChoices(cmd CommandConfig) ([]string, error) {
+	if p.ChoicesCommand == "" {
+		return p.Choices, nil
+	}
+
+	cmd, _, err := resolveSecrets(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	argv := shellArgv(shellDefault, p.ChoicesCommand, nil)
+	choicesCmd := exec.Command(argv[0], argv[1:]...)
+	choicesCmd.Dir = commandDir(cmd)
+	choicesCmd.Env = commandEnviron(cmd)
+
+	output, err := choicesCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load choices for %s: %w", p.Name, err)
+	}
+
+	choices := append([]string{}, p.Choices...)
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			choices = append(choices, line)
+		}
+	}
+	return choices, nil
+}
+
+// checkParams validates the parameter declarations of a command and makes
+// sure every ${param.NAME} reference names a declared parameter
+func checkParams(cmd CommandConfig) error {
+	declared := make(map[string]bool)
+	for _, p := range cmd.Params {
+		if err := p.check(); err != nil {
+			return err
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("duplicate parameter %q", p.Name)
+		}
+		declared[p.Name] = true
+	}
+
+	for _, field := range paramFields(cmd) {
+		for _, match := range paramRefPattern.FindAllStringSubmatch(field, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("reference to undeclared parameter %q", match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// paramFields returns the fields of cmd and its inline steps that may
+// reference parameters
+func paramFields(cmd CommandConfig) []string {
+	fields := append([]string{cmd.Command, cmd.Script, cmd.WorkDir}, cmd.Args...)
+	for _, v := range cmd.Env {
+		fields = append(fields, v)
+	}
+	for _, step := range cmd.allSteps() {
+		if step.Ref == "" {
+			fields = append(fields, paramFields(step.CommandConfig)...)
+		}
+	}
+	return fields
+}
+
+// ResolveParams completes the provided parameter values with defaults and
+// validates them. It is used by the non-interactive `seli run`.
+func ResolveParams(cmd CommandConfig, provided map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	known := make(map[string]bool)
+
+	for _, p := range cmd.Params {
+		known[p.Name] = true
+		value, ok := provided[p.Name]
+		if !ok {
+			if p.Default == "" && p.Kind() != paramBool {
+				return nil, fmt.Errorf("missing value for parameter %q (use --param %s=VALUE)", p.Name, p.Name)
+			}
+			value = p.Default
+			if value == "" {
+				value = "false"
+			}
+		}
+
+		var choices []string
+		if p.Kind() == paramChoice {
+			var err error
+			if choices, err = p.LoadChoices(cmd); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.Check(value, choices); err != nil {
+			return nil, err
+		}
+		values[p.Name] = value
+	}
+
+	for name := range provided {
+		if !known[name] {
+			return nil, fmt.Errorf("command %q has no parameter %q", cmd.Name, name)
+		}
+	}
+	return values, nil
+}
+
+// ApplyParams returns a copy of cmd with every ${param.NAME} placeholder in
+// Command, Script, Args, Env and WorkDir, including those of inline steps,
+// replaced by its value. Values never change how a command line is split: in
+// exec mode the command line is split into words before the placeholders are
+// replaced, and text run by a shell gets the values quoted for that shell.
+func ApplyParams(cmd CommandConfig, values map[string]string) CommandConfig {
+	value := func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+	replace := func(s string) string {
+		return replaceParams(s, value, "")
+	}
+
+	// The shell that runs the text; a script runs in the default shell
+	// when shell is off
+	shell := cmd.Shell
+	if cmd.Script != "" && !shell.Enabled() {
+		shell = shellDefault
+	}
+	replaceShell := func(s string) string {
+		return replaceParams(s, value, shell.Name())
+	}
+
+	// Password values are masked wherever the command is shown, also in the
+	// quoted form the shell gets them in
+	passwords := append([]string(nil), cmd.passwords...)
+	for _, p := range cmd.Params {
+		if v, ok := values[p.Name]; ok && v != "" && p.Kind() == paramPassword {
+			passwords = append(passwords, v, quoteWord(v))
+			if shell.Enabled() {
+				passwords = append(passwords, quoteParam(shell.Name(), v, 0))
+			}
+		}
+	}
+	cmd.passwords = passwords
+
+	// The fields that end up in the text run by the shell
+	inShell := cmd.Shell.Enabled() && cmd.Script == ""
+	switch {
+	case cmd.Script != "":
+		cmd.Script = replaceShell(cmd.Script)
+	case inShell:
+		cmd.Command = replaceShell(cmd.Command)
+	case len(cmd.Args) == 0 && paramRefPattern.MatchString(cmd.Command):
+		if words, err := SplitCommandLine(cmd.Command); err == nil {
+			for i, word := range words {
+				words[i] = replace(word)
+			}
+			cmd.Command = JoinCommandLine(words)
+		} else {
+			cmd.Command = replace(cmd.Command)
+		}
+	default:
+		cmd.Command = replace(cmd.Command)
+	}
+	cmd.WorkDir = replace(cmd.WorkDir)
+
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if inShell {
+			args[i] = replaceShell(arg)
+		} else {
+			args[i] = replace(arg)
+		}
+	}
+	if cmd.Args != nil {
+		cmd.Args = args
+	}
+
+	if cmd.Env != nil {
+		env := make(map[string]string, len(cmd.Env))
+		for k, v := range cmd.Env {
+			env[k] = replace(v)
+		}
+		cmd.Env = env
+	}
+
+	applySteps := func(steps []StepConfig) []StepConfig {
+		if steps == nil {
+			return nil
+		}
+		applied := make([]StepConfig, len(steps))
+		for i, step := range steps {
+			applied[i] = step
+			if step.Ref == "" {
+				applied[i].passwords = passwords
+				applied[i].CommandConfig = ApplyParams(applied[i].CommandConfig, values)
+			}
+		}
+		return applied
+	}
+	cmd.DependsOn = applySteps(cmd.DependsOn)
+	cmd.Steps = applySteps(cmd.Steps)
+	cmd.Parallel = applySteps(cmd.Parallel)
+
+	return cmd
+}
+
+// replaceParams replaces the ${param.NAME} placeholders in s whose value is
+// known. With a shell name set, s is text run by that shell and each value is
+// quoted for the place it appears in.
+func replaceParams(s string, value func(name string) (string, bool), shell string) string {
+	var b strings.Builder
+	var quote rune
+	last := 0
+	for _, m := range paramRefPattern.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:m[0]])
+		if shell != "" {
+			quote = shellQuoteState(shell, s[last:m[0]], quote)
+		}
+		last = m[1]
+
+		v, ok := value(s[m[2]:m[3]])
+		switch {
+		case !ok:
+			v = s[m[0]:m[1]]
+		case shell != "":
+			v = quoteParam(shell, v, quote)
+		}
+		b.WriteString(v)
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// quoteParam quotes v for the named shell, given the quote open where it is
+// inserted. Outside of quotes v becomes a quoted word; inside quotes the
+// characters that would end the quote or expand are escaped. cmd cannot
+// escape % inside double quotes, so there %VAR% in a value is still expanded.
+func quoteParam(shell string, v string, quote rune) string {
+	switch shell {
+	case "cmd":
+		if quote == '"' {
+			return strings.ReplaceAll(v, `"`, `""`)
+		}
+		return quoteCmdWord(v)
+	case "powershell", "pwsh":
+		switch quote {
+		case '\'':
+			return strings.ReplaceAll(v, "'", "''")
+		case '"':
+			return powerShellDoubleQuoteEscaper.Replace(v)
+		}
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+
+	switch quote {
+	case '\'':
+		return strings.ReplaceAll(v, "'", `'\''`)
+	case '"':
+		return shellDoubleQuoteEscaper.Replace(v)
+	}
+	return quoteWord(v)
+}
+
+var (
+	// shellDoubleQuoteEscaper escapes the characters that keep a special
+	// meaning inside double quotes of a POSIX shell
+	shellDoubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	// powerShellDoubleQuoteEscaper does the same for PowerShell, which
+	// escapes with a backtick
+	powerShellDoubleQuoteEscaper = strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$")
+	// cmdEscaper escapes the characters cmd interprets outside of quotes
+	cmdEscaper = strings.NewReplacer(`^`, `^^`, `"`, `^"`, `%`, `^%`, `!`, `^!`, `&`, `^&`, `|`, `^|`, `<`, `^<`, `>`, `^>`, `(`, `^(`, `)`, `^)`)
+)
+
+// quoteCmdWord quotes word as one argument of a program started by cmd. The
+// word is double-quoted the way Windows programs split their command line,
+// then every character cmd interprets, the quotes included, is escaped with
+// ^ so that cmd passes it on unchanged.
+func quoteCmdWord(word string) string {
+	if safeWord(word) && !strings.Contains(word, "%") {
+		return word
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for _, r := range word {
+		switch r {
+		case '\\':
+			backslashes++
+		case '"':
+			// Backslashes before a quote are escaped, and so is the quote
+			b.WriteString(strings.Repeat(`\`, backslashes+1))
+			backslashes = 0
+		default:
+			backslashes = 0
+		}
+		b.WriteRune(r)
+	}
+	// Backslashes before the closing quote are escaped too
+	b.WriteString(strings.Repeat(`\`, backslashes))
+	b.WriteByte('"')
+	return cmdEscaper.Replace(b.String())
+}
+
+// shellQuoteState returns the quote that is open at the end of text run by
+// the named shell, given the quote that is open at its start: a single or
+// double quote, or 0 outside of quotes. cmd only knows double quotes.
+func shellQuoteState(shell string, text string, quote rune) rune {
+	escape := '\\'
+	switch shell {
+	case "cmd":
+		escape = '^'
+	case "powershell", "pwsh":
+		escape = '`'
+	}
+
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == escape && !(shell == "cmd" && quote == '"'):
+			// cmd takes ^ literally inside double quotes
+			escaped = true
+		case r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\'' && shell != "cmd"):
+			quote = r
+		}
+	}
+	return quote
+}
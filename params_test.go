@@ -0,0 +1,258 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestApplyParams(t *testing.T) {
+	cmd := CommandConfig{
+		Name:    "Deploy",
+		Command: "deploy ${param.branch}",
+		Args:    []string{"--env", "${param.env}", "${param.unknown}"},
+		Env:     map[string]string{"BRANCH": "${param.branch}"},
+		WorkDir: "/srv/${param.env}",
+	}
+
+	applied := ApplyParams(cmd, map[string]string{"branch": "main", "env": "staging"})
+
+	if applied.Command != "deploy main" {
+		t.Errorf("Command = %q, want %q", applied.Command, "deploy main")
+	}
+	if strings.Join(applied.Args, " ") != "--env staging ${param.unknown}" {
+		t.Errorf("Args = %q", applied.Args)
+	}
+	if applied.Env["BRANCH"] != "main" {
+		t.Errorf("Env[BRANCH] = %q, want %q", applied.Env["BRANCH"], "main")
+	}
+	if applied.WorkDir != "/srv/staging" {
+		t.Errorf("WorkDir = %q, want %q", applied.WorkDir, "/srv/staging")
+	}
+
+	// The original command must not be modified
+	if cmd.Args[1] != "${param.env}" || cmd.Env["BRANCH"] != "${param.branch}" {
+		t.Error("ApplyParams modified the original command")
+	}
+}
+
+func TestApplyParamsQuoting(t *testing.T) {
+	values := map[string]string{"who": "x'y", "dir": "a b", "evil": `"; echo pwned $HOME`}
+
+	tests := []struct {
+		name string
+		cmd  CommandConfig
+		want []string
+	}{
+		{"exec", CommandConfig{Command: "printf '%s|' ${param.dir} \"${param.who}\""}, []string{"a b", "x'y"}},
+		{"shell", CommandConfig{Command: "printf '%s|' ${param.dir} ${param.evil}", Shell: shellDefault}, []string{"a b", `"; echo pwned $HOME`}},
+		{"shell quotes", CommandConfig{Command: `printf '%s|' "in ${param.evil}" 'in ${param.who}'`, Shell: shellDefault}, []string{`in "; echo pwned $HOME`, "in x'y"}},
+		{"script", CommandConfig{Script: "printf '%s|' ${param.who} \"$1\"", Args: []string{"${param.dir}"}}, []string{"x'y", "a b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argv, err := commandArgv(ApplyParams(tt.cmd, values))
+			if err != nil {
+				t.Fatalf("commandArgv() error = %v", err)
+			}
+			out, err := exec.Command(argv[0], argv[1:]...).Output()
+			if err != nil {
+				t.Fatalf("running %q: %v", argv, err)
+			}
+			if got, want := string(out), strings.Join(tt.want, "|")+"|"; got != want {
+				t.Errorf("output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyParamsQuotingWindowsShells(t *testing.T) {
+	values := map[string]string{"who": "x'y", "dir": `C:\My Files\`, "evil": `"; del %TEMP% & echo pwned $HOME`}
+
+	tests := []struct {
+		name string
+		cmd  CommandConfig
+		want string
+	}{
+		{"cmd", CommandConfig{Command: "echo ${param.who} ${param.dir} ${param.evil}", Shell: "cmd"},
+			`echo ^"x'y^" ^"C:\My Files\\^" ^"\^"; del ^%TEMP^% ^& echo pwned $HOME^"`},
+		{"cmd quotes", CommandConfig{Command: `echo "in ${param.evil}" ^"${param.who}`, Shell: "cmd"},
+			`echo "in ""; del %TEMP% & echo pwned $HOME" ^"^"x'y^"`},
+		{"cmd script", CommandConfig{Script: "echo ${param.dir}", Shell: "CMD.EXE"},
+			`echo ^"C:\My Files\\^"`},
+		{"powershell", CommandConfig{Command: `Write-Output ${param.who} "in ${param.evil}" 'in ${param.who}'`, Shell: "pwsh"},
+			"Write-Output 'x''y' \"in `\"; del %TEMP% & echo pwned `$HOME\" 'in x''y'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argv, err := commandArgv(ApplyParams(tt.cmd, values))
+			if err != nil {
+				t.Fatalf("commandArgv() error = %v", err)
+			}
+			if got := argv[len(argv)-1]; got != tt.want {
+				t.Errorf("shell text = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		param       ParamConfig
+		value       string
+		choices     []string
+		expectError bool
+	}{
+		{"String", ParamConfig{Name: "s"}, "anything", nil, false},
+		{"Int", ParamConfig{Name: "n", Type: paramInt}, "42", nil, false},
+		{"Invalid int", ParamConfig{Name: "n", Type: paramInt}, "forty", nil, true},
+		{"Bool", ParamConfig{Name: "b", Type: paramBool}, "false", nil, false},
+		{"Invalid bool", ParamConfig{Name: "b", Type: paramBool}, "maybe", nil, true},
+		{"Choice", ParamConfig{Name: "c", Type: paramChoice}, "b", []string{"a", "b"}, false},
+		{"Invalid choice", ParamConfig{Name: "c", Type: paramChoice}, "z", []string{"a", "b"}, true},
+		{"Validate pattern", ParamConfig{Name: "v", Validate: `^v\d+$`}, "v12", nil, false},
+		{"Validate pattern mismatch", ParamConfig{Name: "v", Validate: `^v\d+$`}, "12", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.param.Check(tt.value, tt.choices)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Check(%q) error = %v, expectError %v", tt.value, err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	cmd := CommandConfig{
+		Name: "Deploy",
+		Params: []ParamConfig{
+			{Name: "branch", Default: "main"},
+			{Name: "env", Type: paramChoice, ChoicesCommand: "printf 'staging\\nproduction\\n'"},
+			{Name: "force", Type: paramBool},
+		},
+	}
+
+	values, err := ResolveParams(cmd, map[string]string{"env": "production"})
+	if err != nil {
+		t.Fatalf("ResolveParams() error = %v", err)
+	}
+	if values["branch"] != "main" || values["env"] != "production" || values["force"] != "false" {
+		t.Errorf("ResolveParams() = %v", values)
+	}
+
+	if _, err := ResolveParams(cmd, map[string]string{"env": "qa"}); err == nil {
+		t.Error("Expected error for a value missing from the choices command output")
+	}
+	if _, err := ResolveParams(cmd, map[string]string{}); err == nil {
+		t.Error("Expected error for a parameter without value or default")
+	}
+	if _, err := ResolveParams(cmd, map[string]string{"env": "staging", "typo": "x"}); err == nil {
+		t.Error("Expected error for an unknown parameter")
+	}
+}
+
+func TestParamsCheckedAtLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	path := writeTestFile(t, configDir, "deploy.yml", `name: Deploy
+commands:
+  - name: Deploy Branch
+    command: deploy
+    args: ["${param.branch}"]
+    params:
+      - name: branch
+        default: main
+`)
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if config.Commands[0].Args[0] != "${param.branch}" {
+		t.Errorf("Expected parameter placeholder to survive env expansion, got %q", config.Commands[0].Args[0])
+	}
+
+	broken := map[string]string{
+		"undeclared.yml": "name: X\ncommands:\n  - name: A\n    command: echo ${param.nope}\n",
+		"bad-type.yml":   "name: X\ncommands:\n  - name: A\n    command: echo\n    params:\n      - name: p\n        type: color\n",
+		"bad-regex.yml":  "name: X\ncommands:\n  - name: A\n    command: echo\n    params:\n      - name: p\n        validate: \"[\"\n",
+	}
+	for name, content := range broken {
+		if _, err := LoadConfigFile(writeTestFile(t, configDir, name, content), ""); err == nil {
+			t.Errorf("Expected %s to fail to load", name)
+		}
+	}
+}
+
+func TestParamFormSubmitsSubstitutedCommand(t *testing.T) {
+	cmd := CommandConfig{
+		Name:    "Greet",
+		Command: "echo",
+		Args:    []string{"${param.name}", "${param.loud}"},
+		Params: []ParamConfig{
+			{Name: "name", Prompt: "Who?", Validate: `^\w+$`},
+			{Name: "loud", Type: paramBool, Default: "true"},
+		},
+	}
+
+	model := Model{
+		state:         stateViewingCommands,
+		currentConfig: &ConfigFile{Name: "Test", Commands: []CommandConfig{cmd}},
+		list:          list.New(createCommandItems(&ConfigFile{Commands: []CommandConfig{cmd}}), list.NewDefaultDelegate(), 0, 0),
+	}
+
+	model, _ = model.handleEnter()
+	if model.state != stateCollectingParams {
+		t.Fatalf("Expected state to be stateCollectingParams, got %v", model.state)
+	}
+
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	// Submitting an invalid value keeps the form open
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateCollectingParams {
+		t.Fatalf("Expected form to stay open for an invalid value, got state %v", model.state)
+	}
+	if model.form.focus != 0 || model.form.fields[0].err == "" {
+		t.Errorf("Expected the invalid field to be focused with an error")
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("world")})
+	send(tea.KeyMsg{Type: tea.KeyTab})
+	send(tea.KeyMsg{Type: tea.KeyRight})
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if model.state != stateExecutingCommand {
+		t.Fatalf("Expected state to be stateExecutingCommand, got %v", model.state)
+	}
+	if model.pending == nil || strings.Join(model.pending.Args, " ") != "world false" {
+		t.Errorf("Expected substituted args 'world false', got %+v", model.pending)
+	}
+}
+
+func TestParamFormEscCancels(t *testing.T) {
+	cmd := CommandConfig{Name: "Greet", Command: "echo", Params: []ParamConfig{{Name: "name"}}}
+	model := Model{state: stateViewingCommands}
+	model, _ = model.openParamForm(cmd)
+
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = updated.(Model)
+
+	if model.state != stateViewingCommands || model.quitting {
+		t.Errorf("Expected Esc to return to the command list, got state %v quitting %v", model.state, model.quitting)
+	}
+}
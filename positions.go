@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// position is a line and column in a config file, both starting at 1. A zero
+// column means only the line is known.
+type position struct {
+	Line   int
+	Column int
+}
+
+// String formats the position as "line:column" or "line"
+func (p position) String() string {
+	if p.Column == 0 {
+		return strconv.Itoa(p.Line)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// before reports whether p comes before q in the file
+func (p position) before(q position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+// positions maps the dot-separated path of a key or list item, such as
+// "commands.1.workDir", to where it is written in the file
+type positions map[string]position
+
+// find returns the position of path, or of its nearest ancestor that has one
+func (p positions) find(path string) position {
+	for {
+		if pos, ok := p[path]; ok {
+			return pos
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return position{Line: 1}
+		}
+		path = path[:i]
+	}
+}
+
+// configPositions indexes the keys and list items of a config file in the
+// given format (".json", ".yaml", ".yml" or ".toml")
+func configPositions(ext string, data []byte) (positions, error) {
+	switch ext {
+	case ".json":
+		return jsonPositions(data)
+	case ".yaml", ".yml":
+		return yamlPositions(data)
+	case ".toml":
+		return tomlPositions(data), nil
+	}
+	return nil, fmt.Errorf("unsupported file format: %s", ext)
+}
+
+// yamlPositions indexes a YAML document
+func yamlPositions(data []byte) (positions, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	pos := make(positions)
+	if len(doc.Content) > 0 {
+		indexYAMLNode(pos, doc.Content[0], "")
+	}
+	return pos, nil
+}
+
+// indexYAMLNode records the positions of the children of node
+func indexYAMLNode(pos positions, node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			child := joinPath(path, key.Value)
+			pos[child] = position{Line: key.Line, Column: key.Column}
+			indexYAMLNode(pos, node.Content[i+1], child)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := joinPath(path, strconv.Itoa(i))
+			pos[child] = position{Line: item.Line, Column: item.Column}
+			indexYAMLNode(pos, item, child)
+		}
+	case yaml.AliasNode:
+		indexYAMLNode(pos, node.Alias, path)
+	}
+}
+
+// jsonPositions indexes a JSON document
+func jsonPositions(data []byte) (positions, error) {
+	idx := &jsonIndexer{data: data, dec: json.NewDecoder(bytes.NewReader(data)), pos: make(positions)}
+	if err := idx.value(""); err != nil {
+		return nil, err
+	}
+	return idx.pos, nil
+}
+
+// jsonIndexer walks the tokens of a JSON document
+type jsonIndexer struct {
+	data []byte
+	dec  *json.Decoder
+	pos  positions
+}
+
+// next returns the position of the next token
+func (idx *jsonIndexer) next() position {
+	offset := int(idx.dec.InputOffset())
+	for offset < len(idx.data) && strings.IndexByte(" \t\r\n,:", idx.data[offset]) >= 0 {
+		offset++
+	}
+	return offsetPosition(idx.data, offset)
+}
+
+// value indexes the value at path
+func (idx *jsonIndexer) value(path string) error {
+	token, err := idx.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		for idx.dec.More() {
+			at := idx.next()
+			key, err := idx.dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			child := joinPath(path, name)
+			idx.pos[child] = at
+			if err := idx.value(child); err != nil {
+				return err
+			}
+		}
+		_, err = idx.dec.Token()
+	case json.Delim('['):
+		for i := 0; idx.dec.More(); i++ {
+			child := joinPath(path, strconv.Itoa(i))
+			idx.pos[child] = idx.next()
+			if err := idx.value(child); err != nil {
+				return err
+			}
+		}
+		_, err = idx.dec.Token()
+	}
+	return err
+}
+
+// offsetPosition converts a byte offset into a line and column
+func offsetPosition(data []byte, offset int) position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return position{Line: line, Column: column}
+}
+
+// tomlPositions indexes the table headers and keys of a TOML document. Keys
+// inside inline tables and arrays are attributed to the key that holds them.
+func tomlPositions(data []byte) positions {
+	pos := make(positions)
+	counts := make(map[string]int) // array tables seen so far
+	table := ""
+	multiline := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		column := len(text) - len(strings.TrimLeft(text, " \t")) + 1
+
+		// Skip the body of multi-line strings
+		if multiline != "" {
+			if strings.Count(text, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "[["):
+			name := tomlKeyPath(strings.TrimSuffix(strings.TrimPrefix(trimmed[:strings.Index(trimmed, "]]")+2], "[["), "]]"))
+			parent := tomlTablePath(name, counts)
+			table = joinPath(parent, strconv.Itoa(counts[name]))
+			counts[name]++
+			// Nested array tables restart when their parent gets a new item
+			for key := range counts {
+				if strings.HasPrefix(key, name+".") {
+					delete(counts, key)
+				}
+			}
+			pos[table] = position{Line: line, Column: column}
+		case strings.HasPrefix(trimmed, "["):
+			name := tomlKeyPath(strings.TrimSuffix(strings.TrimPrefix(trimmed[:strings.Index(trimmed, "]")+1], "["), "]"))
+			table = tomlTablePath(name, counts)
+			pos[table] = position{Line: line, Column: column}
+		default:
+			eq := strings.Index(trimmed, "=")
+			if eq < 0 {
+				continue
+			}
+			key := joinPath(table, tomlKeyPath(trimmed[:eq]))
+			pos[key] = position{Line: line, Column: column}
+			for _, quote := range []string{`"""`, `'''`} {
+				if strings.Count(trimmed[eq:], quote)%2 == 1 {
+					multiline = quote
+				}
+			}
+		}
+	}
+	return pos
+}
+
+// tomlKeyPath turns a dotted TOML key, possibly with quoted parts, into a path
+func tomlKeyPath(key string) string {
+	var parts []string
+	for _, part := range strings.Split(key, ".") {
+		part = strings.TrimSpace(part)
+		if unquoted, err := strconv.Unquote(part); err == nil {
+			part = unquoted
+		} else {
+			part = strings.Trim(part, "'")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlTablePath returns the path of a table header, inserting the index of
+// the current item of every enclosing array of tables
+func tomlTablePath(name string, counts map[string]int) string {
+	parts := strings.Split(name, ".")
+	path := ""
+	for i, part := range parts {
+		path = joinPath(path, part)
+		prefix := strings.Join(parts[:i+1], ".")
+		if n, ok := counts[prefix]; ok && i < len(parts)-1 {
+			path = joinPath(path, strconv.Itoa(n-1))
+		}
+	}
+	return path
+}
+
+// joinPath appends key to a dot-separated path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// activeProfile is the profile selected with --profile, SELI_PROFILE or the
+// profile switcher of the TUI. Files that do not define it use no profile.
+var activeProfile string
+
+// ProfileConfig is a named set of environment settings of a config file, such
+// as dev, staging or prod
+type ProfileConfig struct {
+	Env      map[string]string `json:"env,omitempty" yaml:"env,omitempty" toml:"env,omitempty"`
+	EnvFiles []string          `json:"envFiles,omitempty" yaml:"envFiles,omitempty" toml:"envFiles,omitempty"`
+}
+
+// profileFromEnv reads the profile selected with SELI_PROFILE
+func profileFromEnv() string {
+	return strings.TrimSpace(os.Getenv("SELI_PROFILE"))
+}
+
+// profileNames returns the names of the profiles of config, sorted
+func profileNames(config *ConfigFile) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nextProfile returns the profile after current in the sorted profiles of
+// config, going back to no profile after the last one
+func nextProfile(config *ConfigFile, current string) string {
+	names := profileNames(config)
+	for i, name := range names {
+		if name == current {
+			if i+1 < len(names) {
+				return names[i+1]
+			}
+			return ""
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// checkProfile returns an error when config has profiles but not the selected
+// one, which is most likely a typo
+func checkProfile(config *ConfigFile) error {
+	if activeProfile == "" || len(config.Profiles) == 0 {
+		return nil
+	}
+	if _, ok := config.Profiles[activeProfile]; ok {
+		return nil
+	}
+	return fmt.Errorf("profile %q is not defined in %s (available: %s)", activeProfile, config.Name, strings.Join(profileNames(config), ", "))
+}
+
+// applyProfile selects the active profile of config, if it defines it, and
+// layers the profile's env files and env over the file-level variables in
+// dotenv
+func applyProfile(config *ConfigFile, configDir string, dotenv map[string]string) error {
+	config.profile = ""
+	profile, ok := config.Profiles[activeProfile]
+	if activeProfile == "" || !ok {
+		return nil
+	}
+	config.profile = activeProfile
+
+	profileEnvVars, err := loadEnvFiles(configDir, profile.EnvFiles, withSystemEnv(dotenv))
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", activeProfile, err)
+	}
+	for k, v := range profileEnvVars {
+		dotenv[k] = v
+	}
+
+	// The profile's env can be referenced like .env variables
+	envVars := withSystemEnv(dotenv)
+	for k, v := range profile.Env {
+		dotenv[k] = ExpandEnvVars(v, envVars)
+	}
+	return nil
+}
+
+// layeredEnv returns the env of cmd: the env inherited from defaults, then
+// the env of the active profile, then the command's own values
+func layeredEnv(config *ConfigFile, cmd *CommandConfig) map[string]string {
+	profile, ok := config.Profiles[config.profile]
+	if config.profile == "" || !ok {
+		profile = ProfileConfig{}
+	}
+	if len(cmd.defaultEnv) == 0 && len(profile.Env) == 0 {
+		return cmd.Env
+	}
+	merged := make(map[string]string, len(cmd.defaultEnv)+len(profile.Env)+len(cmd.Env))
+	for k, v := range cmd.defaultEnv {
+		merged[k] = v
+	}
+	for k, v := range profile.Env {
+		if _, own := cmd.Env[k]; !own {
+			merged[k] = v
+			cmd.setOrigin("env."+k, "profile "+config.profile)
+		}
+	}
+	for k, v := range cmd.Env {
+		merged[k] = v
+	}
+	return merged
+}
+
+// switchProfile selects the next profile of the open config file and loads
+// it again, so that its commands are expanded with the new variables
+func (m Model) switchProfile() (Model, tea.Cmd) {
+	config := m.currentConfig
+	if config == nil || m.folder != "" || len(config.Profiles) == 0 {
+		return m, nil
+	}
+
+	previous := activeProfile
+	activeProfile = nextProfile(config, config.profile)
+	reloaded, err := LoadConfigFile(config.Path, config.rootDir)
+	if err != nil {
+		activeProfile = previous
+		return m.showError(err), nil
+	}
+
+	index := m.list.Index()
+	m.currentConfig = reloaded
+	m.list.SetItems(m.markFavorites(createCommandItems(reloaded)))
+	m.list.Select(index)
+	return m, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// setProfile selects a profile for the duration of a test
+func setProfile(t *testing.T, name string) {
+	t.Helper()
+	previous := activeProfile
+	activeProfile = name
+	t.Cleanup(func() { activeProfile = previous })
+}
+
+const profilesConfig = `name: API
+profiles:
+  staging:
+    envFiles: [.env.staging]
+    env:
+      REGION: eu-west-1
+  prod:
+    env:
+      API_HOST: api.example.com
+      REGION: us-east-1
+commands:
+  - name: Ping
+    command: curl https://${API_HOST}/health
+    env:
+      REGION: ${REGION:-local}
+  - name: Deploy
+    steps:
+      - name: Push
+        command: push --region ${REGION:-local}
+`
+
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, ".env", "API_HOST=localhost\n")
+	writeTestFile(t, configDir, ".env.staging", "API_HOST=staging.example.com\n")
+	path := writeTestFile(t, configDir, "api.yml", profilesConfig)
+
+	tests := []struct {
+		profile string
+		command string
+		region  string
+		push    string
+	}{
+		{"", "curl https://localhost/health", "local", "push --region local"},
+		{"staging", "curl https://staging.example.com/health", "eu-west-1", "push --region eu-west-1"},
+		{"prod", "curl https://api.example.com/health", "us-east-1", "push --region us-east-1"},
+		// Files that do not define the profile use none
+		{"qa", "curl https://localhost/health", "local", "push --region local"},
+	}
+
+	for _, tt := range tests {
+		setProfile(t, tt.profile)
+		config, err := LoadConfigFile(path, "")
+		if err != nil {
+			t.Fatalf("LoadConfigFile() with profile %q error = %v", tt.profile, err)
+		}
+		if got := config.Commands[0].Command; got != tt.command {
+			t.Errorf("profile %q: Command = %q, want %q", tt.profile, got, tt.command)
+		}
+		if got := config.Commands[0].Env["REGION"]; got != tt.region {
+			t.Errorf("profile %q: REGION = %q, want %q", tt.profile, got, tt.region)
+		}
+		if got := config.Commands[1].Steps[0].Command; got != tt.push {
+			t.Errorf("profile %q: step Command = %q, want %q", tt.profile, got, tt.push)
+		}
+	}
+
+	setProfile(t, "qa")
+	config, _ := LoadConfigFile(path, "")
+	if err := checkProfile(config); err == nil || !strings.Contains(err.Error(), "available: prod, staging") {
+		t.Errorf("checkProfile() error = %v, want the available profiles", err)
+	}
+}
+
+func TestNextProfile(t *testing.T) {
+	config := &ConfigFile{Profiles: map[string]ProfileConfig{"prod": {}, "dev": {}, "staging": {}}}
+	want := []string{"dev", "prod", "staging", "", "dev"}
+	current := ""
+	for _, name := range want {
+		current = nextProfile(config, current)
+		if current != name {
+			t.Fatalf("nextProfile() = %q, want %q", current, name)
+		}
+	}
+	if got := nextProfile(&ConfigFile{}, ""); got != "" {
+		t.Errorf("nextProfile() without profiles = %q, want none", got)
+	}
+}
+
+func TestProfileSwitcher(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, ".env", "API_HOST=localhost\n")
+	writeTestFile(t, configDir, ".env.staging", "API_HOST=staging.example.com\n")
+	writeTestFile(t, configDir, "api.yml", profilesConfig)
+	setProfile(t, "")
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+	send(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if activeProfile != "prod" || !strings.Contains(model.View(), "profile: prod") {
+		t.Fatalf("Expected p to select the first profile, got %q", activeProfile)
+	}
+	if got := model.list.SelectedItem().(Item).command.Command; got != "curl https://api.example.com/health" {
+		t.Errorf("Expected the commands to be expanded with the profile, got %q", got)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if activeProfile != "" || strings.Contains(model.View(), "profile:") {
+		t.Errorf("Expected the switcher to cycle back to no profile, got %q", activeProfile)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configRootFlags holds the paths given with --config, which replace the
+// default config roots
+var configRootFlags []string
+
+// projectConfigNames are the single-file project configs discovered next to .seli/
+var projectConfigNames = []string{"seli.yml", "seli.yaml", "seli.json", "seli.toml"}
+
+// ConfigRoot is a location config files are loaded from: a directory browsed
+// like ~/.seli, or a single config file such as seli.yml. Read-only roots were
+// configured explicitly and are never created by seli.
+type ConfigRoot struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// IsFile reports whether the root is a single config file
+func (r ConfigRoot) IsFile() bool {
+	info, err := os.Stat(r.Path)
+	return err == nil && !info.IsDir()
+}
+
+// Dir returns the directory of the root: its path, or the directory a
+// single-file root such as seli.yml is in
+func (r ConfigRoot) Dir() string {
+	if r.IsFile() {
+		return filepath.Dir(r.Path)
+	}
+	return r.Path
+}
+
+// ConfigRoots returns the project configs discovered from the current
+// directory, nearest first, followed by the global config roots
+func ConfigRoots() ([]ConfigRoot, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	globalRoots, err := globalConfigRoots(configRootFlags, os.Getenv("SELI_CONFIG_DIR"), homeDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(discoverProjectRoots(cwd, homeDir), globalRoots...), nil
+}
+
+// globalConfigRoots returns the config roots that do not depend on the current
+// directory. Paths given with --config take precedence over SELI_CONFIG_DIR, a
+// list of paths separated like PATH; both are read-only and skipped when they
+// do not exist. Without either, ~/.seli is used, falling back to
+// $XDG_CONFIG_HOME/seli when that exists and ~/.seli holds no config files,
+// and ~/.seli is created when neither exists.
+func globalConfigRoots(flagPaths []string, envPaths, homeDir string) ([]ConfigRoot, error) {
+	paths := flagPaths
+	if len(paths) == 0 && envPaths != "" {
+		paths = filepath.SplitList(envPaths)
+	}
+
+	if len(paths) > 0 {
+		var roots []ConfigRoot
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			path = expandHome(path, homeDir)
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			if _, err := os.Stat(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping config root %s: %v\n", path, err)
+				continue
+			}
+			roots = append(roots, ConfigRoot{Name: displayPath(path), Path: path, ReadOnly: true})
+		}
+		if len(roots) == 0 {
+			return nil, fmt.Errorf("none of the config roots exist: %s", strings.Join(paths, string(os.PathListSeparator)))
+		}
+		return roots, nil
+	}
+
+	// ~/.seli also holds the history and state, so it may exist without
+	// holding any commands
+	if !hasConfigFiles(filepath.Join(homeDir, ".seli")) {
+		xdgHome := os.Getenv("XDG_CONFIG_HOME")
+		if xdgHome == "" {
+			xdgHome = filepath.Join(homeDir, ".config")
+		}
+		xdgDir := filepath.Join(xdgHome, "seli")
+		if info, err := os.Stat(xdgDir); err == nil && info.IsDir() {
+			return []ConfigRoot{{Name: displayPath(xdgDir), Path: xdgDir, ReadOnly: true}}, nil
+		}
+	}
+
+	configDir, _, err := ScanConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return []ConfigRoot{{Name: displayPath(configDir), Path: configDir}}, nil
+}
+
+// hasConfigFiles reports whether dir or one of its subdirectories contains a
+// config file, ignoring hidden files and directories
+func hasConfigFiles(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && IsConfigFile(entry.Name()) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// rootDirOf returns the directory of the innermost of roots that contains
+// path, or "" when path lies outside all of them
+func rootDirOf(roots []ConfigRoot, path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	best := ""
+	for _, root := range roots {
+		if dir := root.Dir(); isWithin(dir, path) && len(dir) > len(best) {
+			best = dir
+		}
+	}
+	return best
+}
+
+// isWithin reports whether path is dir or lies below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// expandHome replaces a leading ~ in path with homeDir
+func expandHome(path, homeDir string) string {
+	if path == "~" {
+		return homeDir
+	}
+	if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return filepath.Join(homeDir, path[2:])
+	}
+	return path
+}
+
+// discoverProjectRoots looks for .seli/ directories and seli.yml files in dir
+// and its ancestors. The walk stops at the repository root (a directory
+// containing .git), at homeDir, whose .seli is the global config directory,
+// or at the filesystem root.
+func discoverProjectRoots(dir, homeDir string) []ConfigRoot {
+	var roots []ConfigRoot
+
+	for dir != homeDir {
+		name := filepath.Base(dir)
+
+		seliDir := filepath.Join(dir, ".seli")
+		if info, err := os.Stat(seliDir); err == nil && info.IsDir() {
+			roots = append(roots, ConfigRoot{Name: name + "/.seli", Path: seliDir})
+		}
+		for _, configName := range projectConfigNames {
+			path := filepath.Join(dir, configName)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				roots = append(roots, ConfigRoot{Name: name + "/" + configName, Path: path})
+				break
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return roots
+}
+
+// projectRootOf returns the project directory a config file belongs to, or ""
+// for files outside a project. Files below a .seli/ directory belong to the
+// directory containing it, except for the global ~/.seli; a seli.yml belongs
+// to the directory it is in.
+func projectRootOf(path string) string {
+	homeDir, _ := os.UserHomeDir()
+
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == ".seli" {
+			if parent := filepath.Dir(dir); parent != homeDir {
+				return parent
+			}
+			return ""
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	base := filepath.Base(path)
+	for _, configName := range projectConfigNames {
+		if base == configName {
+			return filepath.Dir(path)
+		}
+	}
+	return ""
+}
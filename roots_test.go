@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDiscoverProjectRoots(t *testing.T) {
+	home := t.TempDir()
+	repo := filepath.Join(home, "src", "repo")
+	nested := filepath.Join(repo, "services", "api")
+
+	writeTestFile(t, filepath.Join(repo, ".git"), "HEAD", "ref: refs/heads/main\n")
+	writeTestFile(t, filepath.Join(repo, ".seli"), "dev.yml", "name: Dev\ncommands: []\n")
+	writeTestFile(t, repo, "seli.yml", "name: Repo\ncommands: []\n")
+	writeTestFile(t, nested, "seli.toml", "name = \"API\"\ncommands = []\n")
+	// Configs above the repository root are not discovered
+	writeTestFile(t, filepath.Join(home, "src"), "seli.yml", "name: Outside\ncommands: []\n")
+
+	roots := discoverProjectRoots(nested, home)
+	want := []ConfigRoot{
+		{Name: "api/seli.toml", Path: filepath.Join(nested, "seli.toml")},
+		{Name: "repo/.seli", Path: filepath.Join(repo, ".seli")},
+		{Name: "repo/seli.yml", Path: filepath.Join(repo, "seli.yml")},
+	}
+	if len(roots) != len(want) {
+		t.Fatalf("discoverProjectRoots() = %+v, want %+v", roots, want)
+	}
+	for i := range want {
+		if roots[i] != want[i] {
+			t.Errorf("roots[%d] = %+v, want %+v", i, roots[i], want[i])
+		}
+	}
+
+	// Without a repository the walk stops at the home directory
+	writeTestFile(t, home, "seli.yml", "name: Home\ncommands: []\n")
+	if roots := discoverProjectRoots(filepath.Join(home, "src"), home); len(roots) != 1 || roots[0].Name != "src/seli.yml" {
+		t.Errorf("Expected only src/seli.yml, got %+v", roots)
+	}
+}
+
+func TestProjectWorkDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := filepath.Join(home, "repo")
+
+	project := writeTestFile(t, filepath.Join(repo, ".seli"), "build.yml", "name: Build\ncommands:\n  - name: Web\n    command: make\n    workDir: web\n  - name: Root\n    command: make\n")
+	single := writeTestFile(t, repo, "seli.yml", "name: Repo\ncommands:\n  - name: Docs\n    command: make\n    workDir: docs\n")
+	global := writeTestFile(t, filepath.Join(home, ".seli"), "global.yml", "name: Global\ncommands:\n  - name: Tmp\n    command: ls\n    workDir: tmp\n")
+
+	tests := []struct {
+		path    string
+		command int
+		want    string
+	}{
+		{project, 0, filepath.Join(repo, "web")},
+		{project, 1, ""},
+		{single, 0, filepath.Join(repo, "docs")},
+		{global, 0, "tmp"},
+	}
+
+	for _, tt := range tests {
+		config, err := LoadConfigFile(tt.path, "")
+		if err != nil {
+			t.Fatalf("LoadConfigFile(%s) error = %v", tt.path, err)
+		}
+		if dir := commandDir(config.Commands[tt.command]); dir != tt.want {
+			t.Errorf("%s: commandDir() = %q, want %q", config.Commands[tt.command].Name, dir, tt.want)
+		}
+	}
+}
+
+func TestInitialModelShowsProjectRoots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := filepath.Join(home, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, repo, "seli.yml", "name: Repo\ncommands:\n  - name: Test\n    command: go test ./...\n")
+	writeTestFile(t, filepath.Join(home, ".seli", "ops"), "deploy.yml", "name: Deploy\ncommands:\n  - name: Ship\n    command: ship\n")
+	t.Chdir(repo)
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	if !model.atRoots || len(model.list.Items()) != 2 {
+		t.Fatalf("Expected the project and global roots as sections, got %d items", len(model.list.Items()))
+	}
+
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	// A single-file project root opens its commands directly
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateViewingCommands || model.currentConfig.Name != "Repo" {
+		t.Fatalf("Expected the project commands, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyBackspace})
+	if !model.atRoots {
+		t.Fatal("Expected Backspace to return to the list of roots")
+	}
+
+	// The global root is browsed like before, and Backspace walks back up
+	model.list.Select(1)
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.atRoots || model.list.SelectedItem().(Item).title != "ops/" {
+		t.Fatalf("Expected to browse the global directory, got %+v", model.list.SelectedItem())
+	}
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateViewingCommands {
+		t.Fatalf("Expected ops/deploy.yml to open directly, got state %v", model.state)
+	}
+	send(tea.KeyMsg{Type: tea.KeyBackspace})
+	send(tea.KeyMsg{Type: tea.KeyBackspace})
+	send(tea.KeyMsg{Type: tea.KeyBackspace})
+	if !model.atRoots {
+		t.Error("Expected Backspace to walk back up to the list of roots")
+	}
+}
+
+func TestGlobalConfigRoots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	shared := filepath.Join(home, "team", "commands")
+	personal := filepath.Join(home, "personal")
+	for _, dir := range []string{shared, personal} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(home, "missing")
+
+	paths := func(roots []ConfigRoot) []string {
+		var result []string
+		for _, root := range roots {
+			result = append(result, root.Path)
+		}
+		return result
+	}
+
+	roots, err := globalConfigRoots(nil, shared+string(os.PathListSeparator)+missing+string(os.PathListSeparator)+"~/personal", home)
+	if err != nil {
+		t.Fatalf("globalConfigRoots() error = %v", err)
+	}
+	if got := paths(roots); len(got) != 2 || got[0] != shared || got[1] != personal || !roots[0].ReadOnly {
+		t.Errorf("Expected SELI_CONFIG_DIR roots without the missing one, got %+v", roots)
+	}
+
+	roots, err = globalConfigRoots([]string{personal}, shared, home)
+	if err != nil || len(roots) != 1 || roots[0].Path != personal {
+		t.Errorf("Expected --config to take precedence over SELI_CONFIG_DIR, got %+v, error %v", roots, err)
+	}
+
+	if _, err := globalConfigRoots([]string{missing}, "", home); err == nil {
+		t.Error("Expected an error when no configured root exists")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Configured roots must never be created")
+	}
+
+	// Without configuration, $XDG_CONFIG_HOME/seli is used when ~/.seli holds no config files
+	t.Setenv("HOME", home)
+	xdgDir := filepath.Join(home, ".config", "seli")
+	if err := os.MkdirAll(xdgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	roots, err = globalConfigRoots(nil, "", home)
+	if err != nil || len(roots) != 1 || roots[0].Path != xdgDir {
+		t.Errorf("Expected the XDG fallback, got %+v, error %v", roots, err)
+	}
+
+	// Recording history creates ~/.seli, which must not hide the XDG root
+	if err := AppendHistory(HistoryEntry{Command: "Run"}); err != nil {
+		t.Fatal(err)
+	}
+	roots, err = globalConfigRoots(nil, "", home)
+	if err != nil || len(roots) != 1 || roots[0].Path != xdgDir {
+		t.Errorf("Expected the XDG root to survive a history write, got %+v, error %v", roots, err)
+	}
+
+	writeTestFile(t, filepath.Join(home, ".seli"), "app.yml", "name: App\ncommands: []\n")
+	roots, err = globalConfigRoots(nil, "", home)
+	if err != nil || len(roots) != 1 || roots[0].Path != filepath.Join(home, ".seli") || roots[0].ReadOnly {
+		t.Errorf("Expected ~/.seli to win over the XDG fallback, got %+v, error %v", roots, err)
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	defer func() { configRootFlags = nil }()
+
+	args, err := parseGlobalFlags([]string{"--config", "/a", "--config=/b", "run", "--config", "x"})
+	if err != nil {
+		t.Fatalf("parseGlobalFlags() error = %v", err)
+	}
+	if len(args) != 3 || args[0] != "run" {
+		t.Errorf("Expected the subcommand and its arguments to remain, got %q", args)
+	}
+	if len(configRootFlags) != 2 || configRootFlags[0] != "/a" || configRootFlags[1] != "/b" {
+		t.Errorf("configRootFlags = %q", configRootFlags)
+	}
+
+	if _, err := parseGlobalFlags([]string{"--config"}); err == nil {
+		t.Error("Expected an error for --config without a path")
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaID is where the committed schema is published, for `$schema` references
+const schemaID = "https://raw.githubusercontent.com/shapled/seli/main/seli.schema.json"
+
+// jsonSchema is the subset of JSON Schema (draft-07) that describes seli
+// config files
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Definitions          map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+// schemaDescriptions documents the config keys. Keys are "Type.key" for
+// settings specific to one type, or just "key".
+var schemaDescriptions = map[string]string{
+	"ConfigFile":     "A seli config file: a named group of commands",
+	"CommandConfig":  "A command shown in the launcher",
+	"ParamConfig":    "A value collected before the command runs, referenced as ${param.NAME}",
+	"StepConfig":     "The name of a command in the same file, a reference to a command of another file, or an inline command",
+	"ProfileConfig":  "Environment settings selected with --profile, SELI_PROFILE or the p key",
+	"DefaultsConfig": "Settings inherited by the commands that do not set them",
+	"ConfirmSpec":    "Ask before running: true, a prompt that requires typing the command name, or a prompt and the answer to type",
+
+	"ConfigFile.$schema":     "URL of this schema, for editors",
+	"ConfigFile.name":        "Name of the config file, defaults to the file name",
+	"ConfigFile.show":        "Print the argv, env and working directory of every command before it runs",
+	"ConfigFile.envFiles":    "Extra .env files for every command, relative to the config file",
+	"ConfigFile.shell":       "Shell for commands that do not set their own",
+	"ConfigFile.commands":    "The commands of the file",
+	"ConfigFile.include":     "Config files, relative to this file, whose commands and templates are added to it",
+	"ConfigFile.templates":   "Base commands that commands inherit from with extends",
+	"ConfigFile.profiles":    "Named environment profiles such as dev, staging or prod",
+	"CommandConfig.name":     "Name of the command",
+	"CommandConfig.show":     "Print the argv, env and working directory of the command before it runs",
+	"CommandConfig.envFiles": "Extra .env files for this command, relative to the config file",
+	"CommandConfig.shell":    "Run through a shell: true for the default shell, or a shell name such as bash",
+	"ParamConfig.name":       "Name used in ${param.NAME} placeholders",
+	"ParamConfig.type":       "Kind of input, string by default",
+	"StepConfig.ref":         "Name of the command to run",
+	"StepConfig.file":        "Config file of the command, relative to this file",
+	"DefaultsConfig.shell":   "Shell for commands that do not set their own",
+	"DefaultsConfig.show":    "Print the argv, env and working directory of every command before it runs",
+	"ProfileConfig.env":      "Variables for ${VAR} references and the environment of every command; a command's own env wins",
+	"ProfileConfig.envFiles": "Extra .env files, relative to the config file, loaded after the file-level ones",
+
+	"description":    "Description shown in the list",
+	"stay":           "Return to seli after running a command",
+	"dangerous":      "Ask for confirmation before any command of the file",
+	"exportDotenv":   "Pass .env variables to the environment of executed commands",
+	"command":        "Command line to run, split with shell quoting rules unless args is set",
+	"args":           "Arguments of the command",
+	"env":            "Environment variables of the command",
+	"workDir":        "Working directory, relative to the project root",
+	"script":         "Shell script to run instead of command",
+	"params":         "Values collected before the command runs",
+	"steps":          "Commands run in order instead of command",
+	"dependsOn":      "Commands run once before this command",
+	"parallel":       "Commands started at the same time instead of command",
+	"onError":        "Whether to stop or continue after a failed step",
+	"timeout":        "Maximum run time such as 30s or 5m, after which the command is stopped",
+	"extends":        "Name of the template whose settings the command inherits",
+	"secret":         "Variables whose values are masked as **** in show output, job logs and history",
+	"prompt":         "Label of the form field",
+	"default":        "Value the form starts with",
+	"validate":       "Regular expression the value must match",
+	"choices":        "Values of a choice parameter",
+	"choicesCommand": "Command whose output lines are the values of a choice parameter",
+}
+
+// schemaEnums lists the allowed values of string settings
+var schemaEnums = map[string][]string{
+	"CommandConfig.onError": {onErrorStop, onErrorContinue},
+	"ParamConfig.type":      {paramString, paramChoice, paramBool, paramInt, paramPassword},
+}
+
+// configSchema generates the JSON Schema of config files from the config types
+func configSchema() *jsonSchema {
+	b := &schemaBuilder{definitions: make(map[string]*jsonSchema)}
+	schema := b.object(reflect.TypeOf(ConfigFile{}))
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.ID = schemaID
+	schema.Title = "seli config file"
+	schema.Definitions = b.definitions
+	return schema
+}
+
+// schemaBuilder generates schemas for Go types, collecting the named ones
+type schemaBuilder struct {
+	definitions map[string]*jsonSchema
+}
+
+// typeSchema returns the schema of values of type t
+func (b *schemaBuilder) typeSchema(t reflect.Type) *jsonSchema {
+	switch t {
+	case reflect.TypeOf(ShellSpec("")):
+		return &jsonSchema{Type: []string{"boolean", "string"}}
+	case reflect.TypeOf(ConfirmSpec{}):
+		return b.define(t, func() *jsonSchema {
+			return &jsonSchema{AnyOf: []*jsonSchema{
+				{Type: []string{"boolean", "string"}, Description: "true, or a prompt that requires typing the command name"},
+				{Type: "object", AdditionalProperties: false, Properties: map[string]*jsonSchema{
+					"prompt": {Type: "string", Description: "Text shown above the input"},
+					"answer": {Type: "string", Description: "Text that has to be typed to continue"},
+				}},
+			}}
+		})
+	case reflect.TypeOf(StepConfig{}):
+		return b.define(t, func() *jsonSchema {
+			return &jsonSchema{AnyOf: []*jsonSchema{
+				{Type: "string", Description: "Name of a command in the same file"},
+				b.object(t),
+			}}
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.typeSchema(t.Elem())
+	case reflect.Struct:
+		return b.define(t, func() *jsonSchema { return b.object(t) })
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: b.typeSchema(t.Elem())}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	}
+	return &jsonSchema{Type: "string"}
+}
+
+// define adds the schema of the named type t to the definitions once and
+// returns a reference to it
+func (b *schemaBuilder) define(t reflect.Type, build func() *jsonSchema) *jsonSchema {
+	if _, ok := b.definitions[t.Name()]; !ok {
+		// Reserve the name first so that recursive types terminate
+		b.definitions[t.Name()] = nil
+		schema := build()
+		schema.Description = schemaDescriptions[t.Name()]
+		b.definitions[t.Name()] = schema
+	}
+	return &jsonSchema{Ref: "#/definitions/" + t.Name()}
+}
+
+// object returns the schema of a config struct. Unknown keys are not allowed.
+func (b *schemaBuilder) object(t reflect.Type) *jsonSchema {
+	schema := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema), AdditionalProperties: false}
+	for key, fieldType := range configFields(t) {
+		property := b.typeSchema(fieldType)
+		owner := fieldOwner(t, key)
+		if property.Ref == "" {
+			property.Description = schemaDescription(owner, key)
+			property.Enum = schemaEnums[owner+"."+key]
+		}
+		schema.Properties[key] = property
+	}
+	if t == reflect.TypeOf(ConfigFile{}) {
+		schema.Description = schemaDescriptions[t.Name()]
+	}
+	return schema
+}
+
+// fieldOwner returns the name of the struct that declares key, looking into
+// embedded structs
+func fieldOwner(t reflect.Type, key string) string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			if _, ok := configFields(field.Type)[key]; ok {
+				return fieldOwner(field.Type, key)
+			}
+		}
+	}
+	return t.Name()
+}
+
+// schemaDescription returns the description of key in the struct named owner
+func schemaDescription(owner, key string) string {
+	if description, ok := schemaDescriptions[owner+"."+key]; ok {
+		return description
+	}
+	return schemaDescriptions[key]
+}
+
+// schemaJSON returns the schema as indented JSON
+func schemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaValidator checks a config file, decoded into maps and slices, against
+// the schema
+type schemaValidator struct {
+	root *jsonSchema
+	// foldCase matches keys to properties regardless of case, as in TOML
+	foldCase bool
+	report   func(path, message string)
+}
+
+// validate checks value at path against schema
+func (v *schemaValidator) validate(schema *jsonSchema, value interface{}, path string) {
+	if schema.Ref != "" {
+		schema = v.root.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+	}
+
+	if len(schema.AnyOf) > 0 {
+		var types []string
+		for _, option := range schema.AnyOf {
+			resolved := option
+			if option.Ref != "" {
+				resolved = v.root.Definitions[strings.TrimPrefix(option.Ref, "#/definitions/")]
+			}
+			if schemaAllows(resolved.Type, value) {
+				v.validate(resolved, value, path)
+				return
+			}
+			types = append(types, schemaTypes(resolved.Type)...)
+		}
+		v.report(path, fmt.Sprintf("%s must be %s, got %s", fieldLabel(path), strings.Join(types, " or "), jsonType(value)))
+		return
+	}
+
+	if schema.Type != nil && !schemaAllows(schema.Type, value) {
+		v.report(path, fmt.Sprintf("%s must be %s, got %s", fieldLabel(path), strings.Join(schemaTypes(schema.Type), " or "), jsonType(value)))
+		return
+	}
+
+	if len(schema.Enum) > 0 {
+		if s, ok := value.(string); ok && !containsString(schema.Enum, s) {
+			v.report(path, fmt.Sprintf("%s must be one of %s, got %q", fieldLabel(path), strings.Join(schema.Enum, ", "), s))
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := schema.Properties[key]
+			if !ok && v.foldCase {
+				property, ok = foldedProperty(schema.Properties, key)
+			}
+			switch {
+			case ok:
+				v.validate(property, value[key], joinPath(path, key))
+			case schema.AdditionalProperties == false:
+				v.report(joinPath(path, key), fmt.Sprintf("unknown field %q%s", key, suggestProperty(schema.Properties, key)))
+			default:
+				if additional, ok := schema.AdditionalProperties.(*jsonSchema); ok {
+					v.validate(additional, value[key], joinPath(path, key))
+				}
+			}
+		}
+	default:
+		if schema.Items != nil {
+			for i, item := range rawItems(value) {
+				v.validate(schema.Items, item, joinPath(path, strconv.Itoa(i)))
+			}
+		}
+	}
+}
+
+// schemaTypes returns the type names of a schema's "type"
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// schemaAllows reports whether value has one of the types of a schema's "type"
+func schemaAllows(t interface{}, value interface{}) bool {
+	if t == nil {
+		return true
+	}
+	actual := jsonType(value)
+	for _, name := range schemaTypes(t) {
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type name of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}, []map[string]interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// fieldLabel names the field at path in messages, e.g. "args[1]"
+func fieldLabel(path string) string {
+	parts := strings.Split(path, ".")
+	label := parts[len(parts)-1]
+	if _, err := strconv.Atoi(label); err == nil && len(parts) > 1 {
+		label = fmt.Sprintf("%s[%s]", parts[len(parts)-2], label)
+	}
+	return strconv.Quote(label)
+}
+
+// foldedProperty returns the property that matches key regardless of case
+func foldedProperty(properties map[string]*jsonSchema, key string) (*jsonSchema, bool) {
+	for name, property := range properties {
+		if strings.EqualFold(name, key) {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+// suggestProperty returns a hint for a key that differs from a property only in case
+func suggestProperty(properties map[string]*jsonSchema, key string) string {
+	for name := range properties {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf(" (did you mean %q?)", name)
+		}
+	}
+	return ""
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaCommand implements `seli schema`
+func schemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli schema")
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := schemaJSON()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(data)
+	return 0
+}
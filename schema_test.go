@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaInSync(t *testing.T) {
+	want, err := schemaJSON()
+	if err != nil {
+		t.Fatalf("schemaJSON() error = %v", err)
+	}
+	got, err := os.ReadFile("seli.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read the committed schema: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("seli.schema.json is out of date with the config types; run `go run . schema > seli.schema.json`")
+	}
+}
+
+func TestSchemaCoversConfigFields(t *testing.T) {
+	schema := configSchema()
+	for name, def := range map[string]*jsonSchema{
+		"ConfigFile":     schema,
+		"CommandConfig":  schema.Definitions["CommandConfig"],
+		"ParamConfig":    schema.Definitions["ParamConfig"],
+		"StepConfig":     schema.Definitions["StepConfig"].AnyOf[1],
+		"ProfileConfig":  schema.Definitions["ProfileConfig"],
+		"DefaultsConfig": schema.Definitions["DefaultsConfig"],
+	} {
+		for key, property := range def.Properties {
+			if property.Ref == "" && property.Items == nil && property.Description == "" {
+				t.Errorf("%s.%s has no description", name, key)
+			}
+		}
+	}
+	if _, ok := schema.Properties["$schema"]; !ok {
+		t.Error("Expected config files to accept a $schema key")
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	schema := configSchema()
+	tests := []struct {
+		name     string
+		ext      string
+		content  string
+		problems []string
+	}{
+		{"valid", ".yml", "$schema: " + schemaID + "\nname: Dev\ncommands:\n  - name: Build\n    command: make\n    shell: bash\n    steps: [Lint, {ref: Test, file: test.yml}]\n", nil},
+		{"types", ".yml", "name: Dev\nshow: yes\ncommands:\n  - name: Build\n    args: make\n    env:\n      PORT: 8080\n    steps: [3]\n", []string{
+			`commands.0.args: "args" must be array, got string`,
+			`commands.0.env.PORT: "PORT" must be string, got integer`,
+			`commands.0.steps.0: "steps[0]" must be string or object, got integer`,
+			`show: "show" must be boolean, got string`,
+		}},
+		{"enum", ".json", `{"commands": [{"name": "Deploy", "onError": "ignore", "params": [{"name": "env", "type": "list"}]}]}`, []string{
+			`commands.0.onError: "onError" must be one of stop, continue, got "ignore"`,
+			`commands.0.params.0.type: "type" must be one of string, choice, bool, int, password, got "list"`,
+		}},
+		{"unknown", ".toml", "[[commands]]\nname = \"Build\"\nWorkDir = \"src\"\n\n[[commands.steps]]\nref = \"Lint\"\nfiles = \"lint.yml\"\n", []string{
+			`commands.0.steps.0.files: unknown field "files"`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := decodeRaw(tt.ext, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("decodeRaw() error = %v", err)
+			}
+			var problems []string
+			v := &schemaValidator{root: schema, foldCase: tt.ext == ".toml", report: func(path, message string) {
+				problems = append(problems, fmt.Sprintf("%s: %s", path, message))
+			}}
+			v.validate(schema, raw, "")
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("validate() =\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(tt.problems, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateReportsSchemaProblems(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "types.yml", "name: Types\ncommands:\n  - name: List\n    command: ls\n    show: [yes]\n    onError: skip\n")
+
+	var got []string
+	for _, problem := range validateConfigFile(path, "") {
+		got = append(got, fmt.Sprintf("%s %s", problem.Pos, problem.Message))
+	}
+	want := []string{
+		`5:5 "show" must be boolean, got array`,
+		`6:5 "onError" must be one of stop, continue, got "skip"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("validateConfigFile() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
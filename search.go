@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// Bonuses added to fuzzy match scores so that a match on the command name
+// ranks above an equally good match on its description or command line
+const (
+	nameMatchBonus        = 20
+	descriptionMatchBonus = 10
+	commandMatchBonus     = 0
+)
+
+// searchEntry is a command in the global search index
+type searchEntry struct {
+	config  *ConfigFile
+	command *CommandConfig
+	file    string
+}
+
+// commandLine returns the command line shown and matched for the entry
+func (e searchEntry) commandLine() string {
+	if e.command.Script != "" {
+		return strings.TrimSpace(e.command.Script)
+	}
+	return strings.TrimSpace(strings.Join(append([]string{e.command.Command}, e.command.Args...), " "))
+}
+
+// buildSearchIndex loads every config file below the config root configDir
+// and returns all of their commands. Files that fail to load are skipped.
+func buildSearchIndex(configDir string) ([]searchEntry, error) {
+	var entries []searchEntry
+
+	rootDir := ConfigRoot{Path: configDir}.Dir()
+	err := walkConfigFiles(configDir, func(path, rel string) error {
+		config, err := LoadConfigFile(path, rootDir)
+		if err != nil {
+			return nil
+		}
+		for i := range config.Commands {
+			entries = append(entries, searchEntry{
+				config:  config,
+				command: &config.Commands[i],
+				file:    rel,
+			})
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// buildRootsSearchIndex indexes the commands of every config root. When there
+// is more than one root, the file of each entry is prefixed with its root.
+func buildRootsSearchIndex(roots []ConfigRoot) ([]searchEntry, error) {
+	var entries []searchEntry
+	for _, root := range roots {
+		rootEntries, err := buildSearchIndex(root.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(roots) > 1 {
+			for i := range rootEntries {
+				if root.IsFile() {
+					rootEntries[i].file = root.Name
+				} else {
+					rootEntries[i].file = root.Name + "/" + rootEntries[i].file
+				}
+			}
+		}
+		entries = append(entries, rootEntries...)
+	}
+	return entries, nil
+}
+
+// entryField is a fuzzy.Source over one field of the search entries
+type entryField struct {
+	entries []searchEntry
+	field   func(searchEntry) string
+}
+
+func (f entryField) String(i int) string { return strings.ToLower(f.field(f.entries[i])) }
+func (f entryField) Len() int            { return len(f.entries) }
+
+// rankSearchEntries returns the entries matching query, best match first. A
+// command matches when its name, description or command line fuzzy-matches
+// the query; its score is the best of the three after adding the field bonus.
+func rankSearchEntries(query string, entries []searchEntry) []searchEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return entries
+	}
+
+	fields := []struct {
+		bonus int
+		field func(searchEntry) string
+	}{
+		{nameMatchBonus, func(e searchEntry) string { return e.command.Name }},
+		{descriptionMatchBonus, func(e searchEntry) string { return e.command.Description }},
+		{commandMatchBonus, searchEntry.commandLine},
+	}
+
+	scores := make(map[int]int)
+	for _, f := range fields {
+		for _, match := range fuzzy.FindFrom(query, entryField{entries: entries, field: f.field}) {
+			score := match.Score + f.bonus
+			if best, ok := scores[match.Index]; !ok || score > best {
+				scores[match.Index] = score
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(scores))
+	for index := range scores {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		if scores[indexes[a]] != scores[indexes[b]] {
+			return scores[indexes[a]] > scores[indexes[b]]
+		}
+		return indexes[a] < indexes[b]
+	})
+
+	ranked := make([]searchEntry, len(indexes))
+	for i, index := range indexes {
+		ranked[i] = entries[index]
+	}
+	return ranked
+}
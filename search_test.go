@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// writeSearchFixture creates a config directory with commands in nested files
+func writeSearchFixture(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	writeTestFile(t, configDir, "dev.yml", `name: Dev
+commands:
+  - name: Start Dev Server
+    command: npm run dev
+  - name: Lint
+    description: Run the linters
+    command: golangci-lint run
+`)
+	writeTestFile(t, configDir, "ops/deploy/prod.yml", `name: Production
+commands:
+  - name: Deploy Production
+    description: Ship it
+    command: kubectl apply -f prod.yaml
+`)
+	writeTestFile(t, configDir, "broken.json", "{")
+	return configDir
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	configDir := writeSearchFixture(t)
+
+	entries, err := buildSearchIndex(configDir)
+	if err != nil {
+		t.Fatalf("buildSearchIndex() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 indexed commands, got %d", len(entries))
+	}
+	if entries[2].file != "ops/deploy/prod.yml" || entries[2].command.Name != "Deploy Production" {
+		t.Errorf("Expected nested command with its file path, got %s: %s", entries[2].file, entries[2].command.Name)
+	}
+}
+
+func TestRankSearchEntries(t *testing.T) {
+	configDir := writeSearchFixture(t)
+	entries, err := buildSearchIndex(configDir)
+	if err != nil {
+		t.Fatalf("buildSearchIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"dply", "Deploy Production"},
+		{"linters", "Lint"},
+		{"kubectl", "Deploy Production"},
+		{"start", "Start Dev Server"},
+	}
+
+	for _, tt := range tests {
+		ranked := rankSearchEntries(tt.query, entries)
+		if len(ranked) == 0 {
+			t.Errorf("rankSearchEntries(%q) returned no results", tt.query)
+			continue
+		}
+		if ranked[0].command.Name != tt.expected {
+			t.Errorf("rankSearchEntries(%q) best match = %q, want %q", tt.query, ranked[0].command.Name, tt.expected)
+		}
+	}
+
+	if ranked := rankSearchEntries("zzzz", entries); len(ranked) != 0 {
+		t.Errorf("Expected no results for a query matching nothing, got %d", len(ranked))
+	}
+	if ranked := rankSearchEntries("", entries); len(ranked) != len(entries) {
+		t.Errorf("Expected an empty query to return every command, got %d", len(ranked))
+	}
+}
+
+func TestSearchExecutesAndCancels(t *testing.T) {
+	configDir := writeSearchFixture(t)
+
+	rootItems := []list.Item{Item{title: "dev.yml", description: "Config file"}}
+	model := Model{
+		state:     stateBrowsing,
+		configDir: configDir,
+		list:      list.New(rootItems, list.NewDefaultDelegate(), 0, 0),
+	}
+
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if model.state != stateSearching {
+		t.Fatalf("Expected state to be stateSearching, got %v", model.state)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.state != stateBrowsing || len(model.list.Items()) != 1 || model.quitting {
+		t.Fatalf("Expected Esc to restore the browsing list, got state %v with %d items", model.state, len(model.list.Items()))
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyCtrlP})
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("prod")})
+	if len(model.list.Items()) == 0 || model.list.SelectedItem().(Item).title != "Deploy Production" {
+		t.Fatalf("Expected 'Deploy Production' to be the selected result")
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateExecutingCommand {
+		t.Fatalf("Expected state to be stateExecutingCommand, got %v", model.state)
+	}
+	if model.currentConfig == nil || model.currentConfig.Name != "Production" {
+		t.Errorf("Expected the command's own config to become current, got %+v", model.currentConfig)
+	}
+	if model.pending == nil || model.pending.Name != "Deploy Production" {
+		t.Errorf("Expected 'Deploy Production' to be pending, got %+v", model.pending)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// secretMask replaces secret values in show output, logs and history
+const secretMask = "****"
+
+// SecretResolver looks up the value of a secret reference. A reference is
+// written ${scheme:ref} and resolved by the resolver registered for scheme
+// right before the command is launched, so the value never ends up in the
+// loaded config or in the history.
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to the SecretResolver interface
+type SecretResolverFunc func(ref string) (string, error)
+
+// Resolve calls f(ref)
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// secretResolvers are the resolvers by scheme
+var secretResolvers = map[string]SecretResolver{
+	"file":    SecretResolverFunc(resolveFileSecret),
+	"cmd":     SecretResolverFunc(resolveCommandSecret),
+	"keyring": SecretResolverFunc(resolveKeyringSecret),
+}
+
+// RegisterSecretResolver makes references with the given scheme resolve with r
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	secretResolvers[scheme] = r
+}
+
+// resolveFileSecret reads a secret from a file, e.g. ${file:~/.tokens/github}
+func resolveFileSecret(ref string) (string, error) {
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		ref = expandHome(ref, homeDir)
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveCommandSecret runs a shell command and uses its output as the
+// secret, e.g. ${cmd:pass show github/token}
+func resolveCommandSecret(ref string) (string, error) {
+	argv := shellArgv(shellDefault, ref, nil)
+	var stderr bytes.Buffer
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// resolveKeyringSecret reads a secret from the system keyring. The reference
+// is "service/account", or just "service"; macOS uses the login keychain and
+// other systems the Secret Service through secret-tool.
+func resolveKeyringSecret(ref string) (string, error) {
+	service, account, _ := strings.Cut(ref, "/")
+	var argv []string
+	if runtime.GOOS == "darwin" {
+		argv = []string{"security", "find-generic-password", "-w", "-s", service}
+		if account != "" {
+			argv = append(argv, "-a", account)
+		}
+	} else {
+		argv = []string{"secret-tool", "lookup", "service", service}
+		if account != "" {
+			argv = append(argv, "account", account)
+		}
+	}
+	return resolveCommandSecret(JoinCommandLine(argv))
+}
+
+// secretRef returns the scheme and reference of ${scheme:ref}, given the
+// text between the braces, when scheme has a registered resolver.
+// ${VAR:-default} and the other operators are not references.
+func secretRef(inner string) (scheme, ref string, ok bool) {
+	scheme, ref, found := strings.Cut(inner, ":")
+	if !found || ref == "" || strings.IndexByte("-?+", ref[0]) >= 0 {
+		return "", "", false
+	}
+	if _, registered := secretResolvers[scheme]; !registered {
+		return "", "", false
+	}
+	return scheme, ref, true
+}
+
+// secretResolution resolves the secret references of one command, caching
+// the values so that a reference is resolved once
+type secretResolution struct {
+	values map[string]string
+	err    error
+}
+
+// resolve replaces the secret references in s with their values
+func (r *secretResolution) resolve(s string) string {
+	if r.err != nil || !strings.Contains(s, "${") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], `\$`) {
+			b.WriteString(s[i : i+2])
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := closingBrace(s, i+2)
+		if end < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		scheme, ref, ok := secretRef(s[i+2 : end])
+		if !ok {
+			b.WriteString(s[i : end+1])
+			i = end + 1
+			continue
+		}
+		value, cached := r.values[s[i:end+1]]
+		if !cached {
+			var err error
+			if value, err = secretResolvers[scheme].Resolve(ref); err != nil {
+				r.err = fmt.Errorf("failed to resolve %s: %w", s[i:end+1], err)
+				return s
+			}
+			r.values[s[i:end+1]] = value
+		}
+		b.WriteString(value)
+		i = end + 1
+	}
+	return b.String()
+}
+
+// resolveSecrets returns config with the secret references of its command,
+// script, args, working directory, env and exported .env variables replaced
+// by their values, together with every value that must be masked: the
+// resolved secrets and the values of the variables listed in secret. It must
+// be called once per launch, as resolved values are not references any more.
+func resolveSecrets(config CommandConfig) (CommandConfig, []string, error) {
+	r := &secretResolution{values: make(map[string]string)}
+	config.Command = r.resolve(config.Command)
+	config.Script = r.resolve(config.Script)
+	config.WorkDir = r.resolve(config.WorkDir)
+	if len(config.Args) > 0 {
+		args := make([]string, len(config.Args))
+		for i, arg := range config.Args {
+			args[i] = r.resolve(arg)
+		}
+		config.Args = args
+	}
+	if len(config.Env) > 0 {
+		env := make(map[string]string, len(config.Env))
+		for k, v := range config.Env {
+			env[k] = r.resolve(v)
+		}
+		config.Env = env
+	}
+	if len(config.dotenv) > 0 {
+		dotenv := make(map[string]string, len(config.dotenv))
+		for k, v := range config.dotenv {
+			dotenv[k] = r.resolve(v)
+		}
+		config.dotenv = dotenv
+	}
+	if r.err != nil {
+		return config, nil, fmt.Errorf("command %q: %w", config.Name, r.err)
+	}
+
+	secrets := secretValues(config)
+	for _, value := range r.values {
+		secrets = append(secrets, value)
+	}
+	return config, secrets, nil
+}
+
+// secretValues returns the values of the variables that config marks as
+// secret, looked up in its env and then its exported .env variables, and the
+// values of its password params
+func secretValues(config CommandConfig) []string {
+	values := append([]string(nil), config.passwords...)
+	for _, name := range config.Secret {
+		if value, ok := config.Env[name]; ok {
+			values = append(values, value)
+		} else if value, ok := config.dotenv[name]; ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// maskSecrets replaces every occurrence of the secrets in s with ****. Longer
+// secrets are replaced first so that a secret containing another one is
+// fully masked.
+func maskSecrets(s string, secrets []string) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, secret := range sorted {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, secretMask)
+		}
+	}
+	return s
+}
+
+// maskArgv returns argv with the secrets masked
+func maskArgv(argv []string, secrets []string) []string {
+	if len(secrets) == 0 {
+		return argv
+	}
+	masked := make([]string, len(argv))
+	for i, arg := range argv {
+		masked[i] = maskSecrets(arg, secrets)
+	}
+	return masked
+}
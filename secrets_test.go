@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := writeTestFile(t, dir, "token", "s3cr3t\n")
+	RegisterSecretResolver("test", SecretResolverFunc(func(ref string) (string, error) {
+		return "resolved-" + ref, nil
+	}))
+	t.Cleanup(func() { delete(secretResolvers, "test") })
+
+	config := CommandConfig{
+		Name:    "Deploy",
+		Command: "deploy --token ${file:" + tokenPath + "}",
+		Args:    []string{"${cmd:echo from-command}", "${test:key}", "${file:-not a reference}"},
+		Env:     map[string]string{"API_KEY": "${test:key}", "PLAIN": "value"},
+		WorkDir: "/srv/${test:dir}",
+		Secret:  []string{"PLAIN", "DB_PASSWORD"},
+		dotenv:  map[string]string{"DB_PASSWORD": "hunter2", "TOKEN": "${test:token}"},
+	}
+	resolved, secrets, err := resolveSecrets(config)
+	if err != nil {
+		t.Fatalf("resolveSecrets() error = %v", err)
+	}
+
+	if resolved.Command != "deploy --token s3cr3t" {
+		t.Errorf("Command = %q, want the file contents", resolved.Command)
+	}
+	if got := strings.Join(resolved.Args, " "); got != "from-command resolved-key ${file:-not a reference}" {
+		t.Errorf("Args = %q", got)
+	}
+	if resolved.Env["API_KEY"] != "resolved-key" || config.Env["API_KEY"] != "${test:key}" {
+		t.Errorf("Env = %v, want the references resolved on a copy", resolved.Env)
+	}
+	if resolved.dotenv["TOKEN"] != "resolved-token" || config.dotenv["TOKEN"] != "${test:token}" {
+		t.Errorf("dotenv = %v, want the exported .env references resolved on a copy", resolved.dotenv)
+	}
+	if resolved.WorkDir != "/srv/resolved-dir" {
+		t.Errorf("WorkDir = %q, want the reference resolved", resolved.WorkDir)
+	}
+
+	masked := maskSecrets("s3cr3t from-command resolved-key value hunter2 resolved-token public", secrets)
+	if masked != "**** **** **** **** **** **** public" {
+		t.Errorf("maskSecrets() = %q", masked)
+	}
+
+	config.Args = []string{"${file:" + filepath.Join(dir, "missing") + "}"}
+	if _, _, err := resolveSecrets(config); err == nil || !strings.Contains(err.Error(), `command "Deploy": failed to resolve ${file:`) {
+		t.Errorf("resolveSecrets() error = %v, want the unresolved reference", err)
+	}
+}
+
+func TestSecretsAreMasked(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, "api-key", "key-from-file\n")
+	writeTestFile(t, configDir, ".env", "DB_PASSWORD=hunter2\n")
+	out := filepath.Join(home, "out")
+	path := writeTestFile(t, configDir, "api.yml", `name: API
+show: true
+exportDotenv: true
+commands:
+  - name: Call
+    command: sh
+    args: ["-c", "printf '%s %s' \"$API_KEY\" \"$1\" > `+out+`", "sh", "$DB_PASSWORD"]
+    env:
+      API_KEY: ${file:~/.seli/api-key}
+    secret: [DB_PASSWORD]
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if got := config.Commands[0].Env["API_KEY"]; got != "${file:~/.seli/api-key}" {
+		t.Fatalf("Expected the reference to be kept until launch, got %q", got)
+	}
+
+	stdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err = executeAndRecord(NewCommandExecutor(), config, config.Commands[0], nil)
+	w.Close()
+	os.Stdout = stdout
+	shown, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("executeAndRecord() error = %v", err)
+	}
+
+	if data, _ := os.ReadFile(out); string(data) != "key-from-file hunter2" {
+		t.Errorf("Expected the command to receive the secrets, got %q", data)
+	}
+	if strings.Contains(string(shown), "key-from-file") || strings.Contains(string(shown), "hunter2") || !strings.Contains(string(shown), `API_KEY="****"`) {
+		t.Errorf("Expected the secrets to be masked in the show output, got:\n%s", shown)
+	}
+
+	// $API_KEY is expanded from the command's env when the config is loaded,
+	// so the history holds the reference rather than the key
+	entries, _ := LoadHistory()
+	if len(entries) != 1 || strings.Join(entries[0].Argv, " ") != "sh -c printf '%s %s' \"${file:~/.seli/api-key}\" \"$1\" > "+out+" sh ****" {
+		t.Errorf("Expected the history to mask the secret variable, got %q", entries)
+	}
+}
+
+func TestJobLogMasksSecrets(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := writeTestFile(t, dir, "token", "s3cr3t")
+
+	jobs := NewJobManager(NewCommandExecutor())
+	defer jobs.StopAll()
+	job, err := jobs.Start(CommandConfig{Name: "Leak", Command: "echo token=${file:" + tokenPath + "}", Shell: shellDefault})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitForOutput(t, job, "token=****")
+	if strings.Contains(job.Output(), "s3cr3t") {
+		t.Errorf("Expected the secret to be masked, got %q", job.Output())
+	}
+}
+
+func TestJobSecretsAreResolvedOnce(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "count")
+	marker := filepath.Join(dir, "marker")
+	// A resolved value that looks like a reference must not be resolved again
+	tokenPath := writeTestFile(t, dir, "token", "${cmd:touch "+marker+"}")
+
+	jobs := NewJobManager(NewCommandExecutor())
+	defer jobs.StopAll()
+	job, err := jobs.Start(CommandConfig{
+		Name:    "Once",
+		Command: "echo done ${cmd:echo x >> " + counter + "; echo v} ${file:" + tokenPath + "}",
+		Shell:   shellDefault,
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job.Wait()
+
+	if data, _ := os.ReadFile(counter); string(data) != "x\n" {
+		t.Errorf("Expected the secret command to run once, got %q", data)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("Expected the resolved value not to be resolved again")
+	}
+}
+
+func TestExportedDotenvSecrets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	tokenPath := writeTestFile(t, home, "tok", "s3cr3t\n")
+	writeTestFile(t, configDir, ".env", "TOKEN=${file:"+tokenPath+"}\n")
+	out := filepath.Join(home, "out")
+	path := writeTestFile(t, configDir, "api.yml", `name: API
+exportDotenv: true
+commands:
+  - name: Print
+    command: sh -c 'printenv TOKEN > `+out+`'
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if err := NewCommandExecutor().ExecuteCommand(config.Commands[0], nil); err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "s3cr3t\n" {
+		t.Errorf("Expected the exported .env reference to be resolved, got %q", data)
+	}
+}
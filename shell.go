@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ShellSpec selects the shell a command runs in. Config files write it either
+// as a boolean (`shell: true` uses the default shell) or as the name or path
+// of a shell such as "bash", "zsh" or "/bin/sh".
+type ShellSpec string
+
+const (
+	shellOff     ShellSpec = "false"
+	shellDefault ShellSpec = "true"
+)
+
+// Enabled reports whether commands run through a shell
+func (s ShellSpec) Enabled() bool {
+	return s != "" && s != shellOff
+}
+
+// Program returns the shell executable to run
+func (s ShellSpec) Program() string {
+	if s == "" || s == shellOff || s == shellDefault {
+		if runtime.GOOS == "windows" {
+			return "cmd"
+		}
+		return "sh"
+	}
+	return string(s)
+}
+
+// Name returns the lower-case name of the shell program without directory
+// and extension, such as "sh", "cmd" or "pwsh"
+func (s ShellSpec) Name() string {
+	program := s.Program()
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(program), filepath.Ext(program)))
+}
+
+// set stores a decoded bool or string value
+func (s *ShellSpec) set(value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			*s = shellDefault
+		} else {
+			*s = shellOff
+		}
+	case string:
+		*s = ShellSpec(strings.TrimSpace(v))
+	default:
+		return fmt.Errorf("shell must be a boolean or a shell name, got %T", value)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts a boolean or a string
+func (s *ShellSpec) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return s.set(value)
+}
+
+// MarshalJSON writes booleans back as booleans
+func (s ShellSpec) MarshalJSON() ([]byte, error) {
+	switch s {
+	case shellDefault:
+		return []byte("true"), nil
+	case shellOff:
+		return []byte("false"), nil
+	}
+	return json.Marshal(string(s))
+}
+
+// UnmarshalYAML accepts a boolean or a string
+func (s *ShellSpec) UnmarshalYAML(node *yaml.Node) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return s.set(value)
+}
+
+// UnmarshalTOML accepts a boolean or a string
+func (s *ShellSpec) UnmarshalTOML(value interface{}) error {
+	return s.set(value)
+}
+
+// shellArgv returns the argv that runs script with the given shell. For POSIX
+// shells args become the positional parameters $1, $2, ... of the script;
+// cmd and PowerShell have no such mechanism and get them appended verbatim.
+func shellArgv(shell ShellSpec, script string, args []string) []string {
+	program := shell.Program()
+	switch shell.Name() {
+	case "cmd":
+		return []string{program, "/C", strings.Join(append([]string{script}, args...), " ")}
+	case "powershell", "pwsh":
+		return []string{program, "-NoProfile", "-Command", strings.Join(append([]string{script}, args...), " ")}
+	}
+
+	argv := []string{program, "-c", script}
+	if len(args) > 0 {
+		// The first argument after the script is $0
+		argv = append(argv, program)
+		argv = append(argv, args...)
+	}
+	return argv
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitCommandLine splits a command line into words following POSIX shell
+// quoting rules: single quotes preserve everything literally, double quotes
+// allow backslash escapes of $, `, ", \ and newline, and outside of quotes a
+// backslash escapes the next character. No expansion of any kind is performed.
+func SplitCommandLine(line string) ([]string, error) {
+	return splitCommandWords(line, nil)
+}
+
+// splitCommandWords splits a command line like SplitCommandLine. With expand
+// set, the variable references of the line are replaced by expand(ref) while
+// it is split, so that a value becomes part of a single word whatever spaces
+// or quotes it contains. As in a shell, references inside single quotes or
+// escaped with a backslash are kept as written, and ${...} is one reference
+// even if its default contains spaces.
+func splitCommandWords(line string, expand func(ref string) string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash at position %d", i+1)
+			}
+			i++
+			// Backslash-newline is a line continuation
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+
+		case r == '\'':
+			start := i
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote at position %d", start+1)
+			}
+			inWord = true
+
+		case r == '"':
+			start := i
+			closed := false
+			for i++; i < len(runes); i++ {
+				c := runes[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				if ref := varReference(runes, i, expand); ref != "" {
+					word.WriteString(expand(ref))
+					i += len([]rune(ref)) - 1
+					continue
+				}
+				if c == '\\' && i+1 < len(runes) {
+					switch runes[i+1] {
+					case '$', '`', '"', '\\':
+						i++
+						c = runes[i]
+					case '\n':
+						i++
+						continue
+					}
+				}
+				word.WriteRune(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote at position %d", start+1)
+			}
+			inWord = true
+
+		default:
+			if ref := varReference(runes, i, expand); ref != "" {
+				// Like in a shell, an empty value outside of quotes is no word
+				i += len([]rune(ref)) - 1
+				if value := expand(ref); value != "" {
+					word.WriteString(value)
+					inWord = true
+				}
+				continue
+			}
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// varReference returns the variable reference, ${...} or $NAME, that starts
+// at runes[i], or "" when there is none or expand is nil
+func varReference(runes []rune, i int, expand func(string) string) string {
+	if expand == nil || runes[i] != '$' {
+		return ""
+	}
+	s := string(runes[i:])
+	if strings.HasPrefix(s, "${") {
+		if end := closingBrace(s, 2); end >= 0 {
+			return s[:end+1]
+		}
+		return ""
+	}
+	if len(s) < 2 || !isVarStart(s[1]) {
+		return ""
+	}
+	j := 2
+	for j < len(s) && isVarChar(s[j]) {
+		j++
+	}
+	return s[:j]
+}
+
+// JoinCommandLine is the inverse of SplitCommandLine: it quotes each word so
+// that the result can be pasted into a POSIX shell
+func JoinCommandLine(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = quoteWord(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteWord single-quotes a word unless it consists only of safe characters
+func quoteWord(word string) string {
+	if safeWord(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// safeWord reports whether word is not empty and consists only of characters
+// a shell takes literally
+func safeWord(word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, r := range word {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectError bool
+	}{
+		{"Plain words", "git status --short", []string{"git", "status", "--short"}, false},
+		{"Extra whitespace", "  echo \t a\nb  ", []string{"echo", "a", "b"}, false},
+		{"Double quotes", `git commit -m "hello world"`, []string{"git", "commit", "-m", "hello world"}, false},
+		{"Single quotes", `echo 'it''s' '$HOME'`, []string{"echo", "its", "$HOME"}, false},
+		{"Escaped single quote", `echo 'it'\''s'`, []string{"echo", "it's"}, false},
+		{"Backslash outside quotes", `echo a\ b \"c\"`, []string{"echo", "a b", `"c"`}, false},
+		{"Backslash in double quotes", `echo "a \"b\" \$c \\ \n"`, []string{"echo", `a "b" $c \ \n`}, false},
+		{"Empty quoted words", `printf '' ""`, []string{"printf", "", ""}, false},
+		{"Adjacent quoting", `echo foo"bar"'baz'`, []string{"echo", "foobarbaz"}, false},
+		{"Line continuation", "echo a \\\nb", []string{"echo", "a", "b"}, false},
+		{"Unicode", `echo "héllo wörld"`, []string{"echo", "héllo wörld"}, false},
+		{"Empty input", "", nil, false},
+		{"Unterminated double quote", `echo "hello`, nil, true},
+		{"Unterminated single quote", `echo 'hello`, nil, true},
+		{"Trailing backslash", `echo hello\`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := SplitCommandLine(tt.input)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("SplitCommandLine(%q) error = %v, expectError %v", tt.input, err, tt.expectError)
+			}
+			if strings.Join(words, "\x00") != strings.Join(tt.expected, "\x00") || len(words) != len(tt.expected) {
+				t.Errorf("SplitCommandLine(%q) = %q, want %q", tt.input, words, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJoinCommandLineRoundTrip(t *testing.T) {
+	inputs := [][]string{
+		{"git", "commit", "-m", "hello world"},
+		{"echo", "it's", "$HOME", ""},
+		{"path/to/bin", "--flag=value", "a\"b"},
+	}
+
+	for _, argv := range inputs {
+		line := JoinCommandLine(argv)
+		words, err := SplitCommandLine(line)
+		if err != nil {
+			t.Fatalf("SplitCommandLine(%q) error = %v", line, err)
+		}
+		if strings.Join(words, "\x00") != strings.Join(argv, "\x00") {
+			t.Errorf("round trip of %q through %q gave %q", argv, line, words)
+		}
+	}
+}
+
+func TestUnterminatedQuoteReportedAtLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := writeTestFile(t, filepath.Join(home, ".seli"), "broken.yml", `name: Broken
+commands:
+  - name: Commit
+    command: git commit -m "unfinished
+`)
+
+	_, err := LoadConfigFile(path, "")
+	if err == nil {
+		t.Fatal("Expected an error for an unterminated quote")
+	}
+	if !strings.Contains(err.Error(), `"Commit"`) || !strings.Contains(err.Error(), "unterminated double quote") {
+		t.Errorf("Expected error to name the command and the problem, got %v", err)
+	}
+}
+
+func TestVariablesStayOneArgument(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, ".env", `MSG="it's a b"`+"\n")
+	path := writeTestFile(t, configDir, "echo.yml", `name: Echo
+commands:
+  - name: Echo
+    command: echo ${MSG} "[${MSG}]" ${EMPTY} ${GREETING:-hello world} \$MSG '${MSG} $MSG'
+    env:
+      EMPTY: ""
+`)
+
+	config, err := LoadConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	argv, err := commandArgv(config.Commands[0])
+	if err != nil {
+		t.Fatalf("commandArgv() error = %v", err)
+	}
+	want := []string{"echo", "it's a b", "[it's a b]", "hello world", "$MSG", "${MSG} $MSG"}
+	if strings.Join(argv, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("argv = %q, want %q", argv, want)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxRecentCommands is the number of commands shown in the Recent folder
+const maxRecentCommands = 20
+
+// CommandRef identifies a command by the config file it is defined in
+type CommandRef struct {
+	ConfigPath string `json:"configPath"`
+	Command    string `json:"command"`
+}
+
+// AppState is the state seli persists between sessions
+type AppState struct {
+	Favorites []CommandRef `json:"favorites,omitempty"`
+}
+
+// StatePath returns the path of the state file in ~/.seli/
+func StatePath() (string, error) {
+	configDir, err := ConfigDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, ".state.json"), nil
+}
+
+// LoadState loads the persisted state. A missing state file yields an empty state.
+func LoadState() (*AppState, error) {
+	path, err := StatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &AppState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", path, err)
+	}
+
+	var state AppState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Save writes the state to the state file
+func (s *AppState) Save() error {
+	path, err := StatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// IsFavorite reports whether the command is pinned as a favorite
+func (s *AppState) IsFavorite(ref CommandRef) bool {
+	for _, favorite := range s.Favorites {
+		if favorite == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleFavorite pins or unpins a command and reports whether it is now a favorite
+func (s *AppState) ToggleFavorite(ref CommandRef) bool {
+	for i, favorite := range s.Favorites {
+		if favorite == ref {
+			s.Favorites = append(s.Favorites[:i], s.Favorites[i+1:]...)
+			return false
+		}
+	}
+	s.Favorites = append(s.Favorites, ref)
+	return true
+}
+
+// frecencyWeight scores one execution by its age, so that frequent and recent
+// commands rank first
+func frecencyWeight(age time.Duration) int {
+	switch {
+	case age < 4*time.Hour:
+		return 100
+	case age < 24*time.Hour:
+		return 80
+	case age < 7*24*time.Hour:
+		return 60
+	case age < 30*24*time.Hour:
+		return 40
+	case age < 90*24*time.Hour:
+		return 20
+	}
+	return 10
+}
+
+// rankFrecency returns the executed commands ordered by frecency, at most limit of them
+func rankFrecency(entries []HistoryEntry, now time.Time, limit int) []CommandRef {
+	scores := make(map[CommandRef]int)
+	lastRun := make(map[CommandRef]time.Time)
+	for _, entry := range entries {
+		ref := CommandRef{ConfigPath: entry.ConfigPath, Command: entry.Command}
+		scores[ref] += frecencyWeight(now.Sub(entry.Time))
+		if entry.Time.After(lastRun[ref]) {
+			lastRun[ref] = entry.Time
+		}
+	}
+
+	refs := make([]CommandRef, 0, len(scores))
+	for ref := range scores {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(a, b int) bool {
+		if scores[refs[a]] != scores[refs[b]] {
+			return scores[refs[a]] > scores[refs[b]]
+		}
+		return lastRun[refs[a]].After(lastRun[refs[b]])
+	})
+
+	if limit > 0 && len(refs) > limit {
+		refs = refs[:limit]
+	}
+	return refs
+}
+
+// resolveCommandRefs loads the commands referenced by refs from the config
+// roots, skipping commands whose config file or definition no longer exists
+func resolveCommandRefs(refs []CommandRef, roots []ConfigRoot) []searchEntry {
+	configs := make(map[string]*ConfigFile)
+	var entries []searchEntry
+
+	for _, ref := range refs {
+		config, loaded := configs[ref.ConfigPath]
+		if !loaded {
+			config, _ = LoadConfigFile(ref.ConfigPath, rootDirOf(roots, ref.ConfigPath))
+			configs[ref.ConfigPath] = config
+		}
+		if config == nil {
+			continue
+		}
+		for i := range config.Commands {
+			if config.Commands[i].Name == ref.Command {
+				entries = append(entries, searchEntry{
+					config:  config,
+					command: &config.Commands[i],
+					file:    displayPath(ref.ConfigPath),
+				})
+				break
+			}
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAppStateFavorites(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	state, err := LoadState()
+	if err != nil || len(state.Favorites) != 0 {
+		t.Fatalf("Expected empty state, got %+v, error %v", state, err)
+	}
+
+	deploy := CommandRef{ConfigPath: "/tmp/ops.yml", Command: "Deploy"}
+	if !state.ToggleFavorite(deploy) || !state.IsFavorite(deploy) {
+		t.Fatal("Expected command to be pinned")
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if !loaded.IsFavorite(deploy) {
+		t.Errorf("Expected favorite to be persisted, got %+v", loaded.Favorites)
+	}
+	if loaded.ToggleFavorite(deploy) || loaded.IsFavorite(deploy) {
+		t.Error("Expected second toggle to unpin the command")
+	}
+}
+
+func TestRankFrecency(t *testing.T) {
+	now := time.Now()
+	run := func(command string, age time.Duration) HistoryEntry {
+		return HistoryEntry{Time: now.Add(-age), ConfigPath: "/tmp/ops.yml", Command: command}
+	}
+
+	entries := []HistoryEntry{
+		// Often, but long ago
+		run("Old", 100*24*time.Hour),
+		run("Old", 100*24*time.Hour),
+		run("Old", 100*24*time.Hour),
+		// Daily
+		run("Daily", 2*time.Hour),
+		run("Daily", 26*time.Hour),
+		// Once, just now
+		run("Once", time.Minute),
+	}
+
+	refs := rankFrecency(entries, now, 0)
+	var names []string
+	for _, ref := range refs {
+		names = append(names, ref.Command)
+	}
+	want := []string{"Daily", "Once", "Old"}
+	if len(names) != len(want) {
+		t.Fatalf("rankFrecency() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("rankFrecency() = %v, want %v", names, want)
+		}
+	}
+
+	if refs := rankFrecency(entries, now, 2); len(refs) != 2 {
+		t.Errorf("Expected limit to cap the result, got %d refs", len(refs))
+	}
+}
+
+func TestFavoritesFolder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+	writeTestFile(t, configDir, "a.yml", "name: A\ncommands:\n  - name: Build\n    command: make\n  - name: Test\n    command: make test\n")
+	writeTestFile(t, configDir, "b.yml", "name: B\ncommands:\n  - name: Lint\n    command: make lint\n")
+
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	if items := model.list.Items(); len(items) != 2 {
+		t.Fatalf("Expected no synthetic folders without favorites or history, got %d items", len(items))
+	}
+
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	// Pin the second command of a.yml
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	send(tea.KeyMsg{Type: tea.KeyDown})
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	if title := model.list.SelectedItem().(Item).title; title != "★ Test" {
+		t.Errorf("Expected pinned command to be starred, got %q", title)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	first := model.list.Items()[0].(Item)
+	if first.folder != folderFavorites {
+		t.Fatalf("Expected Favorites folder at the top of the root list, got %+v", first)
+	}
+
+	model.list.Select(0)
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateViewingCommands || len(model.list.Items()) != 1 {
+		t.Fatalf("Expected the Favorites folder to list one command, got state %v with %d items", model.state, len(model.list.Items()))
+	}
+	if model.View() == "" {
+		t.Error("Expected the Favorites folder to render")
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.state != stateExecutingCommand || model.pending.Name != "Test" || model.currentConfig.Name != "A" {
+		t.Errorf("Expected the favorite to execute with its own config, got state %v", model.state)
+	}
+}
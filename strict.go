@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is an error in a config file. Pos is the zero position when the
+// error cannot be attributed to a line.
+type ConfigError struct {
+	Path string
+	Pos  position
+	// Source is the offending line of the file
+	Source string
+	Err    error
+}
+
+// Error formats the error as "path:line:column: message"
+func (e *ConfigError) Error() string {
+	if e.Pos.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s:%s: %v", e.Path, e.Pos, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// newConfigError returns a ConfigError for err at pos, with the source line
+func newConfigError(path string, data []byte, pos position, err error) *ConfigError {
+	e := &ConfigError{Path: path, Pos: pos, Err: err}
+	if pos.Line > 0 {
+		lines := strings.Split(string(data), "\n")
+		if pos.Line <= len(lines) {
+			e.Source = strings.TrimRight(lines[pos.Line-1], "\r")
+		}
+	}
+	return e
+}
+
+// decodeConfig decodes a config file, or another file of seli settings such as
+// _defaults.yml, into config in the format given by ext. In strict mode keys
+// that the config types do not declare are errors.
+func decodeConfig(ext string, data []byte, config interface{}, strict bool) error {
+	switch ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return dec.Decode(config)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(strict)
+		if err := dec.Decode(config); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	case ".toml":
+		md, err := toml.Decode(string(data), config)
+		if err != nil || !strict {
+			return err
+		}
+		for _, key := range md.Undecoded() {
+			if !decodedByUnmarshaler(reflect.TypeOf(config), key) {
+				return fmt.Errorf("unknown field %q", key.String())
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported file format: %s", ext)
+}
+
+// decodedByUnmarshaler reports whether a TOML key lies within a value that a
+// custom unmarshaler decodes; such keys are never marked as decoded
+func decodedByUnmarshaler(t reflect.Type, key toml.Key) bool {
+	unmarshaler := reflect.TypeOf((*toml.Unmarshaler)(nil)).Elem()
+	for _, part := range key {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		fieldType, ok := configFields(t)[part]
+		if !ok {
+			if fieldType, ok = foldedField(part, configFields(t)); !ok {
+				return false
+			}
+		}
+		t = fieldType
+		for elem := t; ; elem = elem.Elem() {
+			if reflect.PointerTo(elem).Implements(unmarshaler) {
+				return true
+			}
+			if elem.Kind() != reflect.Slice && elem.Kind() != reflect.Ptr {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// decodeError returns a ConfigError for an error decoding a file into config,
+// positioned at the first unknown field or wherever the decoder reports it
+func decodeError(path, ext string, data []byte, config interface{}, err error) *ConfigError {
+	if isUnknownFieldError(err) {
+		if unknown := firstUnknownField(path, ext, data, reflect.TypeOf(config).Elem()); unknown != nil {
+			return unknown
+		}
+	}
+	pos, _ := errorPosition(data, err)
+	return newConfigError(path, data, pos, err)
+}
+
+// firstUnknownField returns a ConfigError for the first key of the file that
+// t and the types it contains do not declare, or nil if there is none. It
+// also finds keys that custom unmarshalers, such as the one for steps,
+// silently drop.
+func firstUnknownField(path, ext string, data []byte, t reflect.Type) *ConfigError {
+	raw, err := decodeRaw(ext, data)
+	if err != nil {
+		return nil
+	}
+	pos, err := configPositions(ext, data)
+	if err != nil {
+		return nil
+	}
+
+	var first *unknownField
+	var firstPos position
+	for _, field := range findUnknownFields(raw, t, "", ext == ".toml") {
+		if at := pos.find(field.Path); first == nil || at.before(firstPos) {
+			field := field
+			first, firstPos = &field, at
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return newConfigError(path, data, firstPos, fmt.Errorf("unknown field %q%s", first.Key, first.Hint))
+}
+
+// isUnknownFieldError reports whether err comes from strict decoding
+func isUnknownFieldError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unknown field") || strings.Contains(msg, "not found in type")
+}
+
+// commandError returns a ConfigError for a problem with the command with the
+// given name, positioned at the command
+func commandError(path, ext string, data []byte, name string, err error) *ConfigError {
+	err = fmt.Errorf("command %q: %w", name, err)
+	pos, posErr := configPositions(ext, data)
+	if posErr != nil {
+		return newConfigError(path, data, position{}, err)
+	}
+	raw, _ := decodeRaw(ext, data)
+	for i, cmd := range rawItems(rawField(raw, "commands")) {
+		if rawField(cmd, "name") == name {
+			return newConfigError(path, data, pos.find(joinPath("commands", strconv.Itoa(i))), err)
+		}
+	}
+	return newConfigError(path, data, position{}, err)
+}
+
+// unknownField is a key of a config file that the config types do not declare
+type unknownField struct {
+	Path string
+	Key  string
+	Hint string
+}
+
+// findUnknownFields returns the keys of value, a config file decoded into
+// maps and slices, that the Go type t does not declare. With foldCase, keys
+// match fields regardless of case, as in TOML.
+func findUnknownFields(value interface{}, t reflect.Type, path string, foldCase bool) []unknownField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(ConfirmSpec{}) {
+		// Reports its own unknown keys when it is decoded
+		return nil
+	}
+
+	var unknown []unknownField
+	switch t.Kind() {
+	case reflect.Struct:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			// Scalar forms such as a step written as a command name
+			return nil
+		}
+		known := configFields(t)
+		for key, child := range fields {
+			fieldType, ok := known[key]
+			if !ok && foldCase {
+				fieldType, ok = foldedField(key, known)
+			}
+			if !ok {
+				unknown = append(unknown, unknownField{Path: joinPath(path, key), Key: key, Hint: suggestField(key, known)})
+				continue
+			}
+			unknown = append(unknown, findUnknownFields(child, fieldType, joinPath(path, key), foldCase)...)
+		}
+	case reflect.Slice:
+		for i, item := range rawItems(value) {
+			unknown = append(unknown, findUnknownFields(item, t.Elem(), joinPath(path, strconv.Itoa(i)), foldCase)...)
+		}
+	case reflect.Map:
+		entries, _ := value.(map[string]interface{})
+		for key, child := range entries {
+			unknown = append(unknown, findUnknownFields(child, t.Elem(), joinPath(path, key), foldCase)...)
+		}
+	}
+	return unknown
+}
+
+// rawItems returns the items of a decoded list, including TOML arrays of tables
+func rawItems(value interface{}) []interface{} {
+	if tables, ok := value.([]map[string]interface{}); ok {
+		items := make([]interface{}, len(tables))
+		for i, table := range tables {
+			items[i] = table
+		}
+		return items
+	}
+	items, _ := value.([]interface{})
+	return items
+}
+
+// rawField returns the value of key in a decoded mapping
+func rawField(value interface{}, key string) interface{} {
+	fields, _ := value.(map[string]interface{})
+	return fields[key]
+}
+
+// configFields returns the keys a config struct accepts and their types.
+// Embedded structs contribute their own keys.
+func configFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			for key, fieldType := range configFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || name == "-" || name == "" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// foldedField returns the type of the field that matches key regardless of case
+func foldedField(key string, known map[string]reflect.Type) (reflect.Type, bool) {
+	for name, fieldType := range known {
+		if strings.EqualFold(name, key) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+// suggestField returns a hint for a key that differs from a known one only in case
+func suggestField(key string, known map[string]reflect.Type) string {
+	for name := range known {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf(" (did you mean %q?)", name)
+		}
+	}
+	return ""
+}
+
+// errorPosition extracts the position from a decoding error, if it has one
+func errorPosition(data []byte, err error) (position, bool) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tomlErr toml.ParseError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return offsetPosition(data, int(syntaxErr.Offset)), true
+	case errors.As(err, &typeErr):
+		return offsetPosition(data, int(typeErr.Offset)), true
+	case errors.As(err, &tomlErr):
+		return offsetPosition(data, tomlErr.Position.Start), true
+	}
+
+	// yaml.v3 reports "line N" in the message
+	msg := err.Error()
+	if i := strings.Index(msg, "line "); i >= 0 {
+		var line int
+		if _, scanErr := fmt.Sscanf(msg[i:], "line %d", &line); scanErr == nil && line > 0 {
+			return position{Line: line}, true
+		}
+	}
+	return position{}, false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStrictDecoding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".seli")
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		source  string
+	}{
+		{"field.yml", "name: Dev\ncommands:\n  - name: Build\n    command: make\n    workdir: src\n", `5:5: unknown field "workdir" (did you mean "workDir"?)`, "    workdir: src"},
+		{"step.yml", "name: Dev\ncommands:\n  - name: Build\n    steps:\n      - name: Lint\n        comand: make lint\n", `6:9: unknown field "comand"`, "        comand: make lint"},
+		{"field.json", "{\n  \"name\": \"Dev\",\n  \"commands\": [\n    {\"name\": \"Build\", \"command\": \"make\",\n     \"arg\": [\"-j\"]}\n  ]\n}", `5:6: unknown field "arg"`, `     "arg": ["-j"]}`},
+		{"step.json", "{\"commands\": [{\"name\": \"Build\",\n  \"steps\": [{\"name\": \"Lint\", \"comand\": \"make lint\"}]}]}", `2:30: unknown field "comand"`, ""},
+		{"field.toml", "name = \"Dev\"\n\n[[commands]]\nname = \"Build\"\ncommand = \"make\"\nworkdirr = \"src\"\n", `6:1: unknown field "workdirr"`, `workdirr = "src"`},
+		{"step.toml", "[[commands]]\nname = \"Build\"\n\n[[commands.steps]]\nname = \"Lint\"\ncomand = \"make lint\"\n", `6:1: unknown field "comand"`, `comand = "make lint"`},
+		{"syntax.json", "{\n  \"name\": \"Dev\",\n  \"commands\": [}\n}", `3:17: invalid character '}' looking for beginning of value`, `  "commands": [}`},
+		{"check.yml", "name: Dev\ncommands:\n  - name: Build\n    command: make\n  - name: Quote\n    command: echo \"unterminated\n", `5:5: command "Quote": `, `  - name: Quote`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, configDir, tt.name, tt.content)
+			_, err := LoadConfigFile(path, "")
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("LoadConfigFile() error = %v, want a *ConfigError", err)
+			}
+			if got := strings.TrimPrefix(err.Error(), path+":"); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("LoadConfigFile() error = %q, want prefix %q", got, tt.want)
+			}
+			if tt.source != "" && configErr.Source != tt.source {
+				t.Errorf("Source = %q, want %q", configErr.Source, tt.source)
+			}
+		})
+	}
+
+	// TOML matches keys regardless of case, so this is not a typo
+	path := writeTestFile(t, configDir, "case.toml", "[[commands]]\nname = \"Build\"\ncommand = \"make\"\nWorkDir = \"src\"\n")
+	if _, err := LoadConfigFile(path, ""); err != nil {
+		t.Errorf("LoadConfigFile() error = %v for a key differing only in case", err)
+	}
+}
+
+func TestErrorPanel(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFile(t, filepath.Join(home, ".seli"), "broken.yml", "name: Broken\ncommands:\n  - name: Build\n    command: make\n    workdir: src\n")
+
+	// The only config file is opened directly on start
+	model, err := InitialModel()
+	if err != nil {
+		t.Fatalf("InitialModel() error = %v", err)
+	}
+	send := func(msg tea.Msg) {
+		updated, _ := model.Update(msg)
+		model = updated.(Model)
+	}
+
+	if model.err == nil {
+		t.Fatal("Expected opening a broken file to show the error panel")
+	}
+	view := model.View()
+	for _, want := range []string{"broken.yml:5:5", `unknown field "workdir"`, "5 |     workdir: src", "^"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the error panel to contain %q, got\n%s", want, view)
+		}
+	}
+	if strings.Contains(model.list.Title, "unknown field") {
+		t.Errorf("Expected the error to stay out of the list title, got %q", model.list.Title)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if model.err != nil || model.state != stateBrowsing {
+		t.Errorf("Expected any key to dismiss the panel, got state %v and error %v", model.state, model.err)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -18,7 +20,13 @@ type state int
 const (
 	stateBrowsing state = iota
 	stateViewingCommands
+	stateCollectingParams
+	stateSearching
+	stateHistory
 	stateExecutingCommand
+	stateJobs
+	stateJobLog
+	stateConfirming
 )
 
 // Model represents the application state
@@ -26,11 +34,29 @@ type Model struct {
 	state         state
 	list          list.Model
 	viewport      viewport.Model
+	roots         []ConfigRoot
+	atRoots       bool
 	configDir     string
 	currentPath   string
 	configFiles   []ConfigFile
 	currentConfig *ConfigFile
+	form          paramForm
+	formReturn    state
+	confirm       confirmDialog
+	search        textinput.Model
+	searchIndex   []searchEntry
+	saved         savedView
+	pending       *CommandConfig
+	pendingParams map[string]string
+	appState      *AppState
+	folder        string
 	executor      *CommandExecutor
+	jobs          *JobManager
+	attached      *Job
+	jobTick       int
+	background    bool
+	stay          bool
+	err           error
 	quitting      bool
 	width, height int
 }
@@ -42,9 +68,36 @@ type Item struct {
 	isDir       bool
 	isCommand   bool
 	command     *CommandConfig
+	config      *ConfigFile
+	history     *HistoryEntry
+	folder      string
+	root        *ConfigRoot
+	job         *Job
+	dangerous   bool
 }
 
-func (i Item) Title() string       { return i.title }
+// Synthetic folders shown at the top of the root list
+const (
+	folderFavorites = "★ Favorites"
+	folderRecent    = "Recent"
+)
+
+// savedView is the list state restored when a search or the history is closed
+type savedView struct {
+	state  state
+	items  []list.Item
+	index  int
+	title  string
+	config *ConfigFile
+	folder string
+}
+
+func (i Item) Title() string {
+	if i.dangerous {
+		return dangerStyle.Render("⚠ " + i.title)
+	}
+	return i.title
+}
 func (i Item) Description() string { return i.description }
 func (i Item) FilterValue() string { return i.title }
 
@@ -67,15 +120,44 @@ var (
 	selectedItemStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#EE6FF8")).
 				Bold(true)
+
+	dangerStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF005F")).
+			Bold(true)
+
+	confirmStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#FF005F")).
+			Padding(1, 2)
+
+	profileStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FAFAFA")).
+			Background(lipgloss.Color("#FF875F")).
+			Padding(0, 1)
+
+	errorPanelStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#FF5F87")).
+			Padding(1, 2)
 )
 
 // InitialModel creates the initial model
 func InitialModel() (Model, error) {
-	configDir, entries, err := ScanConfigDir()
+	roots, err := ConfigRoots()
 	if err != nil {
 		return Model{}, err
 	}
 
+	// With a single config root, its directory is browsed directly
+	configDir := roots[len(roots)-1].Path
+	var entries []os.DirEntry
+	if len(roots) == 1 && !roots[0].IsFile() {
+		entries, err = os.ReadDir(configDir)
+		if err != nil {
+			return Model{}, fmt.Errorf("failed to read config directory %s: %w", configDir, err)
+		}
+	}
+
 	// Create list items from directory entries
 	var items []list.Item
 	var configFiles []string
@@ -109,12 +191,28 @@ func InitialModel() (Model, error) {
 	l.SetFilteringEnabled(false)
 	l.Styles.Title = titleStyle
 
+	appState, err := LoadState()
+	if err != nil {
+		// A broken state file must not keep the launcher from starting
+		appState = &AppState{}
+	}
+
+	executor := NewCommandExecutor()
 	model := Model{
 		state:       stateBrowsing,
 		list:        l,
 		configDir:   configDir,
 		currentPath: "", // Start at root config directory
-		executor:    NewCommandExecutor(),
+		roots:       roots,
+		appState:    appState,
+		executor:    executor,
+		jobs:        NewJobManager(executor),
+		stay:        stayInTUI || stayFromEnv(),
+	}
+
+	// Multiple roots, or a single config file, are shown as separate sections
+	if len(roots) > 1 || roots[0].IsFile() {
+		return model.showRoots(), nil
 	}
 
 	// If there's only one config file and no directories, open it directly using the same logic
@@ -123,6 +221,8 @@ func InitialModel() (Model, error) {
 		return updatedModel, nil
 	}
 
+	model.list.SetItems(append(model.folderItems(), items...))
+
 	return model, nil
 }
 
@@ -135,6 +235,34 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.err != nil {
+			return m.updateError(msg)
+		}
+		if m.state == stateCollectingParams {
+			return m.updateParamForm(msg)
+		}
+		if m.state == stateConfirming {
+			return m.updateConfirm(msg)
+		}
+		if m.state == stateSearching {
+			return m.updateSearch(msg)
+		}
+		if m.state == stateJobs || m.state == stateJobLog {
+			if m, cmd, handled := m.updateJobs(msg); handled {
+				return m, cmd
+			}
+		}
+		if m.state == stateHistory {
+			switch msg.Type {
+			case tea.KeyEsc, tea.KeyBackspace:
+				return m.restoreView(), nil
+			case tea.KeyRunes:
+				if len(msg.Runes) > 0 && msg.Runes[0] == 'q' {
+					return m.restoreView(), nil
+				}
+			}
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			m.quitting = true
@@ -143,18 +271,48 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyEnter:
 			return m.handleEnter()
 
+		case tea.KeyCtrlP:
+			if m.state == stateBrowsing || m.state == stateViewingCommands {
+				return m.openSearch()
+			}
+
 		case tea.KeyBackspace:
 			if m.state == stateViewingCommands {
 				return m.goBackToBrowse()
 			}
+			if m.state == stateBrowsing {
+				return m.goUp()
+			}
 
 		case tea.KeyRunes:
 			if len(msg.Runes) > 0 && msg.Runes[0] == 'q' && m.state == stateViewingCommands {
 				return m.goBackToBrowse()
 			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == '/' && (m.state == stateBrowsing || m.state == stateViewingCommands) {
+				return m.openSearch()
+			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'H' && (m.state == stateBrowsing || m.state == stateViewingCommands) {
+				return m.openHistory()
+			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'f' && m.state == stateViewingCommands {
+				return m.toggleFavorite()
+			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'b' && m.state == stateViewingCommands {
+				m.background = true
+				m, cmd := m.handleEnter()
+				// The flag is kept only while the parameter form or confirmation is open
+				m.background = m.state == stateCollectingParams || m.state == stateConfirming
+				return m, cmd
+			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'J' && (m.state == stateBrowsing || m.state == stateViewingCommands) {
+				return m.openJobs()
+			}
+			if len(msg.Runes) > 0 && msg.Runes[0] == 'p' && m.state == stateViewingCommands {
+				return m.switchProfile()
+			}
 
 		case tea.KeyUp:
-			if m.state == stateBrowsing || m.state == stateViewingCommands {
+			if m.state == stateBrowsing || m.state == stateViewingCommands || m.state == stateHistory {
 				// Handle cycling logic BEFORE letting the list process the key
 				if len(m.list.Items()) > 0 {
 					currentIndex := m.list.Index()
@@ -166,7 +324,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case tea.KeyDown:
-			if m.state == stateBrowsing || m.state == stateViewingCommands {
+			if m.state == stateBrowsing || m.state == stateViewingCommands || m.state == stateHistory {
 				// Handle cycling logic BEFORE letting the list process the key
 				if len(m.list.Items()) > 0 {
 					currentIndex := m.list.Index()
@@ -178,14 +336,49 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case jobTickMsg:
+		if msg.id != m.jobTick || (m.state != stateJobs && m.state != stateJobLog) {
+			return m, nil
+		}
+		return m.refreshJobs(), jobTick(msg.id)
+
+	case jobExitedMsg:
+		return m.refreshJobs(), nil
+
+	case jobRestartedMsg:
+		if msg.err != nil {
+			return m.showError(msg.err), nil
+		}
+		return m.refreshJobs(), waitForJob(msg.job)
+
+	case commandFinishedMsg:
+		// The command has already reported its result before returning
+		m.pending = nil
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
-		m.list.SetSize(msg.Width, msg.Height-4)
+		m.viewport.Width, m.viewport.Height = msg.Width, max(msg.Height-2, 0)
+		if m.state == stateSearching {
+			m.list.SetSize(msg.Width, msg.Height-5)
+		} else {
+			m.list.SetSize(msg.Width, msg.Height-4)
+		}
 	}
 
 	// Update list based on current state
+	if m.state == stateCollectingParams {
+		return m.updateParamForm(msg)
+	}
+	if m.state == stateConfirming {
+		return m.updateConfirm(msg)
+	}
+	if m.state == stateSearching {
+		return m.updateSearch(msg)
+	}
+
 	var cmd tea.Cmd
-	if m.state == stateBrowsing || m.state == stateViewingCommands {
+	if m.state == stateBrowsing || m.state == stateViewingCommands || m.state == stateHistory || m.state == stateJobs {
 		m.list, cmd = m.list.Update(msg)
 	}
 
@@ -197,22 +390,60 @@ func (m Model) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.err != nil {
+		return m.errorView()
+	}
+
+	if m.state == stateCollectingParams {
+		return m.form.View()
+	}
+	if m.state == stateConfirming {
+		return m.confirmView()
+	}
 
 	content := m.list.View()
+	if m.state == stateJobLog {
+		content = m.viewport.View()
+	}
+	if m.state == stateSearching {
+		content = lipgloss.JoinVertical(lipgloss.Left, m.search.View(), content)
+	}
 
 	// Add status bar at bottom
 	var status string
 	switch m.state {
 	case stateBrowsing:
+		if m.atRoots {
+			status = statusStyle.Render(fmt.Sprintf("Browsing: %d config roots", len(m.roots)))
+			break
+		}
 		path := m.configDir
 		if m.currentPath != "" {
 			path = filepath.Join(m.configDir, m.currentPath)
 		}
 		status = statusStyle.Render(fmt.Sprintf("Browsing: %s", path))
 	case stateViewingCommands:
-		status = statusStyle.Render(fmt.Sprintf("Commands: %s", m.currentConfig.Name))
+		name := m.folder
+		if m.currentConfig != nil {
+			name = m.currentConfig.Name
+		}
+		status = statusStyle.Render(fmt.Sprintf("Commands: %s", name))
+	case stateSearching:
+		status = statusStyle.Render(fmt.Sprintf("Search: %d of %d commands", len(m.list.Items()), len(m.searchIndex)))
+	case stateHistory:
+		status = statusStyle.Render(fmt.Sprintf("History: %d executions", len(m.list.Items())))
 	case stateExecutingCommand:
 		status = statusStyle.Render("Executing command...")
+	case stateJobs:
+		status = statusStyle.Render(fmt.Sprintf("Jobs: %d running of %d", m.jobs.Running(), len(m.jobs.Jobs())))
+	case stateJobLog:
+		status = statusStyle.Render(fmt.Sprintf("Job [%d] %s: %s", m.attached.ID, m.attached.Name, m.attached.Status(time.Now())))
+	}
+	if activeProfile != "" {
+		status = lipgloss.JoinHorizontal(lipgloss.Top, status, profileStyle.Render("profile: "+activeProfile))
+	}
+	if running := m.jobs.Running(); running > 0 && m.state != stateJobs && m.state != stateJobLog {
+		status = lipgloss.JoinHorizontal(lipgloss.Top, status, statusStyle.Render(fmt.Sprintf("%d jobs running (J)", running)))
 	}
 
 	if m.height > 0 {
@@ -232,6 +463,12 @@ func (m Model) handleEnter() (Model, tea.Cmd) {
 
 	switch m.state {
 	case stateBrowsing:
+		if item.folder != "" {
+			return m.openFolder(item.folder)
+		}
+		if item.root != nil {
+			return m.openRoot(*item.root)
+		}
 		if item.isDir {
 			return m.enterDirectory(item.title)
 		} else {
@@ -240,13 +477,58 @@ func (m Model) handleEnter() (Model, tea.Cmd) {
 
 	case stateViewingCommands:
 		if item.isCommand && item.command != nil {
+			if item.config != nil {
+				m.currentConfig = item.config
+			}
+			if len(item.command.Params) > 0 {
+				return m.openParamForm(*item.command)
+			}
+			m.pendingParams = nil
 			return m.executeCommand(*item.command)
 		}
+
+	case stateHistory:
+		if item.history != nil {
+			return m.rerunHistoryEntry(*item.history)
+		}
 	}
 
 	return m, nil
 }
 
+// openParamForm shows the parameter form of a command
+func (m Model) openParamForm(cmd CommandConfig) (Model, tea.Cmd) {
+	m.form = newParamForm(cmd)
+	m.formReturn = m.state
+	m.state = stateCollectingParams
+	return m, textinput.Blink
+}
+
+// updateParamForm handles messages while the parameter form is shown
+func (m Model) updateParamForm(msg tea.Msg) (Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.Type {
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case tea.KeyEsc:
+			// Cancel and return to the command list
+			m.state = m.formReturn
+			m.background = false
+			return m, nil
+		}
+	}
+
+	form, cmd, submitted := m.form.Update(msg)
+	m.form = form
+	if submitted {
+		m.pendingParams = form.values()
+		return m.executeCommand(ApplyParams(form.command, m.pendingParams))
+	}
+	return m, cmd
+}
+
 // enterDirectory enters a subdirectory
 func (m Model) enterDirectory(dirName string) (Model, tea.Cmd) {
 	newPath := filepath.Join(m.currentPath, strings.TrimSuffix(dirName, "/"))
@@ -254,8 +536,7 @@ func (m Model) enterDirectory(dirName string) (Model, tea.Cmd) {
 
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
-		m.list.Title = errorStyle.Render(fmt.Sprintf("Error: %v", err))
-		return m, nil
+		return m.showError(err), nil
 	}
 
 	var items []list.Item
@@ -303,13 +584,12 @@ func (m Model) enterDirectory(dirName string) (Model, tea.Cmd) {
 func (m Model) openConfigFile(filename string) (Model, tea.Cmd) {
 	fullPath := filepath.Join(m.configDir, m.currentPath, filename)
 
-	config, err := LoadConfigFile(fullPath)
+	config, err := LoadConfigFile(fullPath, rootDirOf(m.roots, fullPath))
 	if err != nil {
-		m.list.Title = errorStyle.Render(fmt.Sprintf("Error: %v", err))
-		return m, nil
+		return m.showError(err), nil
 	}
 
-	items := createCommandItems(config)
+	items := m.markFavorites(createCommandItems(config))
 
 	m.state = stateViewingCommands
 	m.currentConfig = config
@@ -332,11 +612,16 @@ func createCommandItems(config *ConfigFile) []list.Item {
 		if description == "" {
 			description = cmd.Command
 		}
+		if description == "" {
+			description = cmd.stepSummary()
+		}
 		items = append(items, Item{
 			title:       cmd.Name,
 			description: description,
 			isCommand:   true,
 			command:     &cmd,
+			config:      config,
+			dangerous:   commandConfirm(config, cmd).Enabled(),
 		})
 	}
 	return items
@@ -344,16 +629,20 @@ func createCommandItems(config *ConfigFile) []list.Item {
 
 // goBackToBrowse returns to directory browsing
 func (m Model) goBackToBrowse() (Model, tea.Cmd) {
+	if m.atRoots {
+		return m.showRoots(), nil
+	}
+
 	m.state = stateBrowsing
 	m.currentConfig = nil
+	m.folder = ""
 
 	// Reload directory contents
 	var items []list.Item
 	fullPath := filepath.Join(m.configDir, m.currentPath)
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
-		m.list.Title = errorStyle.Render(fmt.Sprintf("Error: %v", err))
-		return m, nil
+		return m.showError(err), nil
 	}
 
 	for _, entry := range entries {
@@ -373,6 +662,10 @@ func (m Model) goBackToBrowse() (Model, tea.Cmd) {
 		}
 	}
 
+	if m.currentPath == "" && len(m.roots) <= 1 {
+		items = append(m.folderItems(), items...)
+	}
+
 	m.list.SetItems(items)
 	title := "Seli - Command Launcher"
 	if m.currentPath != "" {
@@ -383,6 +676,70 @@ func (m Model) goBackToBrowse() (Model, tea.Cmd) {
 	return m, nil
 }
 
+// goUp returns from a directory to its parent, and from the top of a config
+// root to the list of roots
+func (m Model) goUp() (Model, tea.Cmd) {
+	if m.atRoots {
+		return m, nil
+	}
+	if m.currentPath != "" {
+		m.currentPath = filepath.Dir(m.currentPath)
+		if m.currentPath == "." {
+			m.currentPath = ""
+		}
+		return m.goBackToBrowse()
+	}
+	if len(m.roots) > 1 {
+		return m.showRoots(), nil
+	}
+	return m, nil
+}
+
+// showRoots lists the config roots as top-level sections, below the
+// Favorites and Recent folders
+func (m Model) showRoots() Model {
+	items := m.folderItems()
+	for _, root := range m.roots {
+		root := root
+		description := displayPath(root.Path)
+		if root.ReadOnly {
+			description += " · read-only"
+		}
+		items = append(items, Item{
+			title:       root.Name,
+			description: description,
+			isDir:       true,
+			root:        &root,
+		})
+	}
+
+	m.state = stateBrowsing
+	m.atRoots = true
+	m.currentConfig = nil
+	m.folder = ""
+	m.currentPath = ""
+	m.list.SetItems(items)
+	m.list.Select(0)
+	m.list.Title = titleStyle.Render("Seli - Command Launcher")
+	return m
+}
+
+// openRoot opens a config root from the list of roots. A single-file root
+// shows its commands directly.
+func (m Model) openRoot(root ConfigRoot) (Model, tea.Cmd) {
+	m.currentPath = ""
+	if root.IsFile() {
+		m.configDir = filepath.Dir(root.Path)
+		return m.openConfigFile(filepath.Base(root.Path))
+	}
+
+	m.configDir = root.Path
+	m.atRoots = false
+	m, cmd := m.goBackToBrowse()
+	m.list.Select(0)
+	return m, cmd
+}
+
 // handleUp handles up key press with cycling
 func (m Model) handleUp() (Model, tea.Cmd) {
 	items := m.list.Items()
@@ -419,10 +776,312 @@ func (m Model) handleDown() (Model, tea.Cmd) {
 	return m, nil
 }
 
-// executeCommand executes the selected command
+// executeCommand executes the selected command, asking for confirmation first
+// if the command or any command it runs needs it
 func (m Model) executeCommand(cmd CommandConfig) (Model, tea.Cmd) {
+	confirms, err := planConfirms(m.currentConfig, cmd)
+	if err != nil {
+		m.background = false
+		return m.showError(err), nil
+	}
+	if len(confirms) > 0 {
+		return m.openConfirm(confirms, cmd)
+	}
+	return m.runSelected(cmd)
+}
+
+// runSelected runs a command in the background, or with the TUI suspended when
+// seli stays open; otherwise seli exits and runs it
+func (m Model) runSelected(cmd CommandConfig) (Model, tea.Cmd) {
+	if m.background {
+		m.background = false
+		return m.startJob(cmd)
+	}
+	if m.shouldStay() {
+		if m.state == stateCollectingParams {
+			m.state = m.formReturn
+		}
+		m.pending = &cmd
+		return m, m.runInTUI(cmd)
+	}
+
 	m.state = stateExecutingCommand
+	m.pending = &cmd
 	m.list.Title = statusStyle.Render(fmt.Sprintf("Executing: %s", cmd.Name))
 
 	return m, tea.Quit
 }
+
+// openSearch indexes every command below the config directory and shows the
+// global search
+func (m Model) openSearch() (Model, tea.Cmd) {
+	roots := m.roots
+	if len(roots) == 0 {
+		roots = []ConfigRoot{{Path: m.configDir}}
+	}
+	index, err := buildRootsSearchIndex(roots)
+	if err != nil {
+		return m.showError(err), nil
+	}
+
+	m = m.saveView()
+
+	m.search = textinput.New()
+	m.search.Prompt = "/ "
+	m.search.Placeholder = "Search all commands"
+	m.search.Focus()
+
+	m.searchIndex = index
+	m.state = stateSearching
+	if m.height > 0 {
+		// Make room for the search input above the list
+		m.list.SetSize(m.width, m.height-5)
+	}
+	m.list.Title = titleStyle.Render("Search")
+	m.list.SetItems(searchItems(index))
+	m.list.Select(0)
+
+	return m, textinput.Blink
+}
+
+// updateSearch handles messages while the global search is shown
+func (m Model) updateSearch(msg tea.Msg) (Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.Type {
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case tea.KeyEsc:
+			return m.restoreView(), nil
+
+		case tea.KeyEnter:
+			selectedItem := m.list.SelectedItem()
+			if selectedItem == nil {
+				return m, nil
+			}
+			item := selectedItem.(Item)
+			m.currentConfig = item.config
+			if len(item.command.Params) > 0 {
+				return m.openParamForm(*item.command)
+			}
+			m.pendingParams = nil
+			return m.executeCommand(*item.command)
+
+		case tea.KeyUp:
+			return m.handleUp()
+
+		case tea.KeyDown:
+			return m.handleDown()
+		}
+	}
+
+	query := m.search.Value()
+	var cmd tea.Cmd
+	m.search, cmd = m.search.Update(msg)
+	if m.search.Value() != query {
+		m.list.SetItems(searchItems(rankSearchEntries(m.search.Value(), m.searchIndex)))
+		m.list.Select(0)
+	}
+	return m, cmd
+}
+
+// saveView remembers the current list so that restoreView can return to it
+func (m Model) saveView() Model {
+	m.saved = savedView{
+		state:  m.state,
+		items:  m.list.Items(),
+		index:  m.list.Index(),
+		title:  m.list.Title,
+		config: m.currentConfig,
+		folder: m.folder,
+	}
+	return m
+}
+
+// restoreView restores the list shown before the search or history was opened
+func (m Model) restoreView() Model {
+	m.state = m.saved.state
+	m.currentConfig = m.saved.config
+	m.folder = m.saved.folder
+	m.list.SetItems(m.saved.items)
+	m.list.Select(m.saved.index)
+	m.list.Title = m.saved.title
+	m.searchIndex = nil
+	if m.height > 0 {
+		m.list.SetSize(m.width, m.height-4)
+	}
+	return m
+}
+
+// searchItems creates list items for search results, showing the file each
+// command comes from
+func searchItems(entries []searchEntry) []list.Item {
+	items := make([]list.Item, len(entries))
+	for i, entry := range entries {
+		description := entry.command.Description
+		if description == "" {
+			description = entry.commandLine()
+		}
+		items[i] = Item{
+			title:       entry.command.Name,
+			description: entry.file + " · " + description,
+			isCommand:   true,
+			command:     entry.command,
+			config:      entry.config,
+			dangerous:   commandConfirm(entry.config, *entry.command).Enabled(),
+		}
+	}
+	return items
+}
+
+// openHistory shows the recorded executions, most recent first
+func (m Model) openHistory() (Model, tea.Cmd) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return m.showError(err), nil
+	}
+
+	m = m.saveView()
+	m.state = stateHistory
+	m.list.Title = titleStyle.Render("History")
+	m.list.SetItems(historyItems(entries, time.Now()))
+	m.list.Select(0)
+
+	return m, nil
+}
+
+// rerunHistoryEntry runs a recorded execution again with the same parameters.
+// If the recorded parameters no longer fit the command, the parameter form is
+// shown instead.
+func (m Model) rerunHistoryEntry(entry HistoryEntry) (Model, tea.Cmd) {
+	config, cmd, values, err := loadHistoryCommand(entry, m.roots)
+	if err != nil && config == nil {
+		return m.showError(err), nil
+	}
+
+	m.currentConfig = config
+	if err != nil {
+		m, teaCmd := m.openParamForm(cmd)
+		for i := range m.form.fields {
+			if value, ok := entry.Params[m.form.fields[i].param.Name]; ok && m.form.fields[i].isTextField() {
+				m.form.fields[i].input.SetValue(value)
+			}
+		}
+		return m, teaCmd
+	}
+
+	m.pendingParams = values
+	return m.executeCommand(cmd)
+}
+
+// historyItems creates list items for history entries, most recent first
+func historyItems(entries []HistoryEntry, now time.Time) []list.Item {
+	items := make([]list.Item, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		items = append(items, Item{
+			title:       entry.Command,
+			description: describeHistoryEntry(entry, now),
+			history:     &entry,
+		})
+	}
+	return items
+}
+
+// folderItems returns the synthetic Favorites and Recent folders shown at the
+// top of the root list. Empty folders are left out.
+func (m Model) folderItems() []list.Item {
+	var items []list.Item
+	if m.appState != nil && len(m.appState.Favorites) > 0 {
+		items = append(items, Item{
+			title:       folderFavorites,
+			description: fmt.Sprintf("Pinned commands (%d)", len(m.appState.Favorites)),
+			isDir:       true,
+			folder:      folderFavorites,
+		})
+	}
+	if entries, err := LoadHistory(); err == nil && len(entries) > 0 {
+		items = append(items, Item{
+			title:       folderRecent,
+			description: "Frequently and recently run commands",
+			isDir:       true,
+			folder:      folderRecent,
+		})
+	}
+	return items
+}
+
+// openFolder shows the commands of a synthetic folder
+func (m Model) openFolder(folder string) (Model, tea.Cmd) {
+	var refs []CommandRef
+	switch folder {
+	case folderFavorites:
+		if m.appState != nil {
+			refs = m.appState.Favorites
+		}
+	case folderRecent:
+		entries, err := LoadHistory()
+		if err != nil {
+			return m.showError(err), nil
+		}
+		refs = rankFrecency(entries, time.Now(), maxRecentCommands)
+	}
+
+	items := m.markFavorites(searchItems(resolveCommandRefs(refs, m.roots)))
+
+	m.state = stateViewingCommands
+	m.currentConfig = nil
+	m.folder = folder
+	m.list.SetItems(items)
+	m.list.Select(0)
+	m.list.Title = titleStyle.Render(folder)
+
+	return m, nil
+}
+
+// markFavorites prefixes the titles of favorite commands with a star
+func (m Model) markFavorites(items []list.Item) []list.Item {
+	if m.appState == nil {
+		return items
+	}
+	for i, listItem := range items {
+		item := listItem.(Item)
+		if item.config != nil && item.command != nil && m.appState.IsFavorite(commandRef(item)) {
+			item.title = "★ " + item.command.Name
+			items[i] = item
+		}
+	}
+	return items
+}
+
+// toggleFavorite pins or unpins the selected command and saves the state
+func (m Model) toggleFavorite() (Model, tea.Cmd) {
+	selectedItem := m.list.SelectedItem()
+	if selectedItem == nil {
+		return m, nil
+	}
+	item := selectedItem.(Item)
+	if item.config == nil || item.command == nil {
+		return m, nil
+	}
+	if m.appState == nil {
+		m.appState = &AppState{}
+	}
+
+	item.title = item.command.Name
+	if m.appState.ToggleFavorite(commandRef(item)) {
+		item.title = "★ " + item.command.Name
+	}
+	m.list.SetItem(m.list.Index(), item)
+
+	if err := m.appState.Save(); err != nil {
+		m = m.showError(err)
+	}
+	return m, nil
+}
+
+// commandRef returns the reference persisted for the command of an item
+func commandRef(item Item) CommandRef {
+	return CommandRef{ConfigPath: item.config.Path, Command: item.command.Name}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an issue found in a config file by `seli validate`
+type Problem struct {
+	File    string
+	Pos     position
+	Message string
+}
+
+// String formats the problem as "file:line:column: message"
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%s: %s", p.File, p.Pos, p.Message)
+}
+
+// validateConfigFile loads the config file at path, found in the config root
+// directory rootDir, and reports every problem found in it. Problems are
+// sorted by position.
+func validateConfigFile(path, rootDir string) []Problem {
+	v := &validator{file: relativePath(path), rootDir: rootDir}
+	v.check(path)
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].Pos.before(v.problems[j].Pos)
+	})
+	return v.problems
+}
+
+// validator collects the problems of one config file
+type validator struct {
+	file     string
+	rootDir  string
+	pos      positions
+	problems []Problem
+}
+
+// report adds a problem at the position of the given path
+func (v *validator) report(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{File: v.file, Pos: v.pos.find(path), Message: fmt.Sprintf(format, args...)})
+}
+
+// reportError adds a problem for an error loading the file, at the position
+// the error names if there is one
+func (v *validator) reportError(data []byte, err error) {
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		pos := configErr.Pos
+		if pos.Line == 0 {
+			pos = position{Line: 1}
+		}
+		// Errors in .env files are reported in the .env file
+		v.problems = append(v.problems, Problem{File: relativePath(configErr.Path), Pos: pos, Message: configErr.Err.Error()})
+		return
+	}
+
+	pos, ok := errorPosition(data, err)
+	if !ok {
+		pos = position{Line: 1}
+	}
+	v.problems = append(v.problems, Problem{File: v.file, Pos: pos, Message: err.Error()})
+}
+
+// reported reports whether a load error is on a line where one of the first n
+// problems, found by the schema, already is. A value of the wrong type is
+// reported by both.
+func (v *validator) reported(data []byte, err error, n int) bool {
+	pos, ok := errorPosition(data, err)
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		pos, ok = configErr.Pos, configErr.Pos.Line > 0 && relativePath(configErr.Path) == v.file
+	}
+	if !ok {
+		return false
+	}
+	for _, problem := range v.problems[:n] {
+		if problem.Pos.Line == pos.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// check runs every check on the file at path
+func (v *validator) check(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.problems = append(v.problems, Problem{File: v.file, Pos: position{Line: 1}, Message: err.Error()})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if v.pos, err = configPositions(ext, data); err != nil {
+		v.reportError(data, err)
+		return
+	}
+
+	raw, err := decodeRaw(ext, data)
+	if err != nil {
+		v.reportError(data, err)
+		return
+	}
+	schema := configSchema()
+	checker := &schemaValidator{
+		root: schema,
+		// TOML matches keys to fields regardless of case
+		foldCase: ext == ".toml",
+		report: func(path, message string) {
+			v.report(path, "%s", message)
+		},
+	}
+	checker.validate(schema, raw, "")
+	schemaProblems := len(v.problems)
+
+	// Unknown fields are reported above, so the remaining checks can still run
+	// on a permissive load
+	config, err := loadConfigFile(path, v.rootDir, false)
+	if err != nil {
+		if !v.reported(data, err, schemaProblems) {
+			v.reportError(data, err)
+		}
+		return
+	}
+
+	// Included commands follow the file's own and are checked in their file
+	commands := config.Commands
+	if own := len(rawItems(rawField(raw, "commands"))); own < len(commands) {
+		commands = commands[:own]
+	}
+
+	seen := make(map[string]int)
+	for i, cmd := range commands {
+		path := joinPath("commands", strconv.Itoa(i))
+		if cmd.Name == "" {
+			v.report(path, "command has no name")
+		} else if first, ok := seen[cmd.Name]; ok {
+			v.report(path+".name", "duplicate command name %q (first defined on line %d)", cmd.Name, v.pos.find(joinPath("commands", strconv.Itoa(first))).Line)
+		} else {
+			seen[cmd.Name] = i
+		}
+		v.checkCommand(cmd, path)
+	}
+}
+
+// checkCommand checks what a command runs, including its inline steps
+func (v *validator) checkCommand(cmd CommandConfig, path string) {
+	if cmd.Command == "" && cmd.Script == "" && !cmd.isChain() && len(cmd.Parallel) == 0 {
+		v.report(path, "command %q has no command, script, steps or parallel commands", cmd.Name)
+	}
+
+	for _, undefined := range cmd.undefinedVars {
+		v.report(joinPath(path, undefined.Field), "undefined variable ${%s}", undefined.Name)
+	}
+
+	dir := commandDir(cmd)
+	if dir != "" && !strings.Contains(dir, "${param.") {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			v.report(path+".workDir", "workDir %q does not exist", dir)
+		}
+	}
+
+	if cmd.Command != "" || cmd.Script != "" {
+		field := "command"
+		if cmd.Shell.Enabled() || cmd.Script != "" {
+			field = "shell"
+		}
+		if argv, err := commandArgv(cmd); err == nil && !strings.Contains(argv[0], "${param.") {
+			if !executableExists(argv[0], dir) {
+				v.report(joinPath(path, field), "executable %q not found in PATH", argv[0])
+			}
+		}
+	}
+
+	for _, group := range []struct {
+		key   string
+		steps []StepConfig
+	}{{"dependsOn", cmd.DependsOn}, {"steps", cmd.Steps}, {"parallel", cmd.Parallel}} {
+		for i, step := range group.steps {
+			if step.Ref == "" {
+				v.checkCommand(step.CommandConfig, joinPath(path, group.key+"."+strconv.Itoa(i)))
+			}
+		}
+	}
+}
+
+// executableExists reports whether program can be run. Programs given as a
+// path are looked up relative to dir.
+func executableExists(program, dir string) bool {
+	if !strings.ContainsRune(program, '/') && !strings.ContainsRune(program, filepath.Separator) {
+		_, err := exec.LookPath(program)
+		return err == nil
+	}
+	if !filepath.IsAbs(program) && dir != "" {
+		program = filepath.Join(dir, program)
+	}
+	info, err := os.Stat(program)
+	return err == nil && !info.IsDir()
+}
+
+// decodeRaw decodes a config file into maps and slices
+func decodeRaw(ext string, data []byte) (interface{}, error) {
+	var raw interface{}
+	var err error
+	switch ext {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var table map[string]interface{}
+		_, err = toml.Decode(string(data), &table)
+		raw = table
+	default:
+		err = fmt.Errorf("unsupported file format: %s", ext)
+	}
+	return raw, err
+}
+
+// relativePath returns path relative to the working directory when it is
+// below it, so that editors and CI can open the reported positions
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// validateCommand implements `seli validate [path]`
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: seli validate [path]")
+	}
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	// A file or directory given as argument is still loaded with the
+	// _defaults files of the config root it is in
+	roots, err := ConfigRoots()
+	var dirs []string
+	if len(positional) == 1 {
+		if _, err := os.Stat(positional[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		dirs = []string{positional[0]}
+	} else {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for _, root := range roots {
+			dirs = append(dirs, root.Path)
+		}
+	}
+
+	files, problems := 0, 0
+	for _, dir := range dirs {
+		err := walkConfigFiles(dir, func(path, rel string) error {
+			files++
+			for _, problem := range validateConfigFile(path, rootDirOf(roots, path)) {
+				problems++
+				fmt.Println(problem)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems in %d config files\n", problems, files)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d config files OK\n", files)
+	return 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigPositions(t *testing.T) {
+	files := map[string]string{
+		".yml": `name: Dev
+commands:
+  - name: Build
+    command: make
+  - name: Serve
+    workDir: web
+    env:
+      PORT: "8080"
+`,
+		".json": `{
+  "name": "Dev",
+  "commands": [
+    {"name": "Build", "command": "make"},
+    {
+      "name": "Serve",
+      "workDir": "web",
+      "env": {
+        "PORT": "8080"
+      }
+    }
+  ]
+}`,
+		".toml": `name = "Dev"
+
+[[commands]]
+name = "Build"
+command = "make"
+
+[[commands]]
+name = "Serve"
+workDir = "web"
+
+[commands.env]
+PORT = "8080"
+`,
+	}
+	want := map[string]map[string]int{
+		".yml":  {"commands.0": 3, "commands.1": 5, "commands.1.workDir": 6, "commands.1.env.PORT": 8, "commands.1.missing": 5},
+		".json": {"commands.0": 4, "commands.1": 5, "commands.1.workDir": 7, "commands.1.env.PORT": 9, "commands.1.missing": 5},
+		".toml": {"commands.0": 3, "commands.1": 7, "commands.1.workDir": 9, "commands.1.env.PORT": 12, "commands.1.missing": 7},
+	}
+
+	for ext, content := range files {
+		pos, err := configPositions(ext, []byte(content))
+		if err != nil {
+			t.Fatalf("configPositions(%s) error = %v", ext, err)
+		}
+		for path, line := range want[ext] {
+			if got := pos.find(path).Line; got != line {
+				t.Errorf("%s: line of %s = %d, want %d", ext, path, got, line)
+			}
+		}
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SELI_TEST_DEFINED", "1")
+	configDir := filepath.Join(home, ".seli")
+
+	path := writeTestFile(t, configDir, "broken.yml", `name: Broken
+commands:
+  - name: Build
+    command: make
+    workdir: src
+  - command: echo unnamed
+  - name: Build
+    command: echo again
+  - name: Deploy
+    command: echo ${SELI_TEST_UNDEFINED} ${SELI_TEST_DEFINED}
+    workDir: missing
+  - name: Tool
+    command: seli-test-no-such-tool --help
+  - name: Empty
+    description: nothing to run
+`)
+
+	var got []string
+	for _, problem := range validateConfigFile(path, "") {
+		got = append(got, fmt.Sprintf("%s %s", problem.Pos, problem.Message))
+	}
+	want := []string{
+		`5:5 unknown field "workdir" (did you mean "workDir"?)`,
+		`6:5 command has no name`,
+		`7:5 duplicate command name "Build" (first defined on line 3)`,
+		`10:5 undefined variable ${SELI_TEST_UNDEFINED}`,
+		`11:5 workDir "missing" does not exist`,
+		`13:5 executable "seli-test-no-such-tool" not found in PATH`,
+		`14:5 command "Empty" has no command, script, steps or parallel commands`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("validateConfigFile() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	syntax := writeTestFile(t, configDir, "syntax.json", "{\n  \"name\": \"X\",\n  \"commands\": [\n    {\"name\": }\n  ]\n}")
+	problems := validateConfigFile(syntax, "")
+	if len(problems) != 1 || problems[0].Pos.Line != 4 {
+		t.Errorf("Expected a syntax error on line 4, got %v", problems)
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, "configs")
+	writeTestFile(t, dir, "ok.yml", "name: OK\ncommands:\n  - name: List\n    command: ls\n")
+
+	if code := validateCommand([]string{dir}); code != 0 {
+		t.Errorf("validateCommand() = %d for valid files, want 0", code)
+	}
+
+	writeTestFile(t, dir, "bad.toml", "name = \"Bad\"\n\n[[commands]]\nname = \"List\"\ncommand = \"ls\"\nargz = [\"-l\"]\n")
+	if code := validateCommand([]string{dir}); code != 1 {
+		t.Errorf("validateCommand() = %d with an unknown field, want 1", code)
+	}
+	if code := validateCommand([]string{filepath.Join(dir, "ok.yml")}); code != 0 {
+		t.Errorf("validateCommand() = %d for a single valid file, want 0", code)
+	}
+}